@@ -0,0 +1,222 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"matrix-blockchain/node"
+	"matrix-blockchain/staking"
+	"matrix-blockchain/transaction"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// Server exposes the node's chain data over HTTP as JSON.
+type Server struct {
+	node *node.Node
+	mux  *http.ServeMux
+}
+
+// NewServer creates an API server for a node.
+func NewServer(n *node.Node) *Server {
+	s := &Server{node: n, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /finality", s.handleFinalizedHeight)
+	s.mux.HandleFunc("GET /blocks/{height}/finality", s.handleBlockFinality)
+	s.mux.HandleFunc("GET /validators/{height}", s.handleValidators)
+	s.mux.HandleFunc("GET /receipts/{hash}", s.handleReceipt)
+	s.mux.HandleFunc("GET /memos/{tag}/receipts", s.handleMemoReceipts)
+	s.mux.HandleFunc("GET /delegators/{address}/unbondings", s.handleUnbondings)
+	s.mux.HandleFunc("GET /delegators/{address}/redelegations", s.handleRedelegations)
+	s.mux.HandleFunc("GET /delegators/{address}/rewards", s.handleRewards)
+	s.mux.HandleFunc("GET /treasury", s.handleTreasury)
+	s.mux.HandleFunc("GET /treasury/grants", s.handleGrants)
+	s.mux.HandleFunc("GET /proposals/{id}", s.handleProposal)
+	s.mux.HandleFunc("GET /supply", s.handleSupply)
+	s.mux.HandleFunc("GET /supply/audit", s.handleSupplyAudit)
+	s.mux.HandleFunc("GET /blocks/{height}/supply", s.handleBlockSupply)
+	return s
+}
+
+// Start serves the API on a given port.
+func (s *Server) Start(port string) error {
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return fmt.Errorf("failed to start API server: %v", err)
+	}
+
+	fmt.Printf("API server started on port %s\n", port)
+	go func() {
+		if err := http.Serve(listener, s.mux); err != nil {
+			fmt.Printf("API server stopped: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+// handleFinalizedHeight returns the height of the latest finalized block.
+func (s *Server) handleFinalizedHeight(w http.ResponseWriter, r *http.Request) {
+	height, err := s.node.FinalizedHeight()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, map[string]int64{"finalized_height": height})
+}
+
+// handleBlockFinality returns the finality status of a block with the commit
+// certificate light clients verify it against.
+func (s *Server) handleBlockFinality(w http.ResponseWriter, r *http.Request) {
+	height, err := heightParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	status, err := s.node.FinalityStatus(height)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, status)
+}
+
+// handleValidators returns the validator set voting at a height.
+func (s *Server) handleValidators(w http.ResponseWriter, r *http.Request) {
+	height, err := heightParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	validators := s.node.ValidatorSet(height)
+	if validators == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no validator set for height %d", height))
+		return
+	}
+	writeJSON(w, validators)
+}
+
+// handleReceipt returns the receipt of a committed transaction.
+func (s *Server) handleReceipt(w http.ResponseWriter, r *http.Request) {
+	receipt, err := s.node.Receipt(r.PathValue("hash"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, receipt)
+}
+
+// handleMemoReceipts returns the receipts of committed transactions whose
+// memo carries a tag.
+func (s *Server) handleMemoReceipts(w http.ResponseWriter, r *http.Request) {
+	receipts, err := s.node.ReceiptsByMemoTag(r.PathValue("tag"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if receipts == nil {
+		receipts = []transaction.Receipt{}
+	}
+	writeJSON(w, receipts)
+}
+
+// handleUnbondings returns a delegator's pending unbondings.
+func (s *Server) handleUnbondings(w http.ResponseWriter, r *http.Request) {
+	unbondings := s.node.PendingUnbondings(r.PathValue("address"))
+	if unbondings == nil {
+		unbondings = []staking.UnbondingEntry{}
+	}
+	writeJSON(w, unbondings)
+}
+
+// handleRedelegations returns a delegator's redelegations that have not matured yet.
+func (s *Server) handleRedelegations(w http.ResponseWriter, r *http.Request) {
+	redelegations := s.node.PendingRedelegations(r.PathValue("address"))
+	if redelegations == nil {
+		redelegations = []staking.RedelegationEntry{}
+	}
+	writeJSON(w, redelegations)
+}
+
+// handleRewards returns the rewards a delegator can withdraw.
+func (s *Server) handleRewards(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]int64{"rewards": s.node.PendingRewards(r.PathValue("address"))})
+}
+
+// handleTreasury returns the research treasury's balance.
+func (s *Server) handleTreasury(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.node.Treasury())
+}
+
+// handleGrants returns the history of grants awarded from the treasury.
+func (s *Server) handleGrants(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.node.Grants())
+}
+
+// handleProposal returns a governance proposal.
+func (s *Server) handleProposal(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid proposal ID: %s", r.PathValue("id")))
+		return
+	}
+	proposal, err := s.node.Proposal(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, proposal)
+}
+
+// handleSupply returns the current supply report.
+func (s *Server) handleSupply(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.node.Supply())
+}
+
+// handleSupplyAudit replays the chain to verify the supply and returns the
+// audited supply report.
+func (s *Server) handleSupplyAudit(w http.ResponseWriter, r *http.Request) {
+	report, err := s.node.AuditSupply()
+	if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, report)
+}
+
+// handleBlockSupply returns the supply minted and burned by a block.
+func (s *Server) handleBlockSupply(w http.ResponseWriter, r *http.Request) {
+	height, err := heightParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	record, err := s.node.BlockSupply(height)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, record)
+}
+
+// heightParam parses the height path parameter.
+func heightParam(r *http.Request) (int64, error) {
+	height, err := strconv.ParseInt(r.PathValue("height"), 10, 64)
+	if err != nil || height < 0 {
+		return 0, fmt.Errorf("invalid height: %s", r.PathValue("height"))
+	}
+	return height, nil
+}
+
+// writeJSON writes a value as a JSON response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Printf("Failed to write API response: %v\n", err)
+	}
+}
+
+// writeError writes an error as a JSON response with the given status.
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,102 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"matrix-blockchain/transaction"
+)
+
+// CommitCertificate proves a block was finalized: it carries the signed
+// precommits of validators holding more than 2/3 of the voting power.
+type CommitCertificate struct {
+	Height     int64   `json:"height"`
+	Round      int     `json:"round"`
+	BlockHash  string  `json:"block_hash"`
+	Precommits []*Vote `json:"precommits"`
+}
+
+// NewCommitCertificate collects the precommits for blockHash from a vote set.
+func NewCommitCertificate(precommits *VoteSet, blockHash string) *CommitCertificate {
+	cert := &CommitCertificate{
+		Height:    precommits.Height,
+		Round:     precommits.Round,
+		BlockHash: blockHash,
+	}
+	for _, vote := range precommits.Votes() {
+		if vote.BlockHash == blockHash {
+			cert.Precommits = append(cert.Precommits, vote)
+		}
+	}
+	return cert
+}
+
+// Encode serializes the certificate for inclusion in the next block.
+func (cc *CommitCertificate) Encode() ([]byte, error) {
+	data, err := json.Marshal(cc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode commit: %v", err)
+	}
+	return data, nil
+}
+
+// DecodeCommitCertificate parses a certificate carried in a block.
+func DecodeCommitCertificate(data []byte) (*CommitCertificate, error) {
+	var cert CommitCertificate
+	if err := json.Unmarshal(data, &cert); err != nil {
+		return nil, fmt.Errorf("invalid commit certificate: %v", err)
+	}
+	return &cert, nil
+}
+
+// Signers returns the IDs of the validators whose precommits are in the certificate.
+func (cc *CommitCertificate) Signers() map[string]bool {
+	signers := make(map[string]bool, len(cc.Precommits))
+	for _, vote := range cc.Precommits {
+		signers[vote.ValidatorID] = true
+	}
+	return signers
+}
+
+// Verify checks every precommit signature against the validator set and that
+// the signers hold more than 2/3 of the voting power.
+func (cc *CommitCertificate) Verify(validators *ValidatorSet) error {
+	if cc.BlockHash == "" {
+		return fmt.Errorf("commit certificate for height %d has no block hash", cc.Height)
+	}
+
+	var power int64
+	signed := make(map[string]bool)
+	for _, vote := range cc.Precommits {
+		if vote.Type != Precommit || vote.Height != cc.Height || vote.Round != cc.Round || vote.BlockHash != cc.BlockHash {
+			return fmt.Errorf("precommit from %s does not match the certificate", vote.ValidatorID)
+		}
+		if signed[vote.ValidatorID] {
+			return fmt.Errorf("duplicate precommit from %s", vote.ValidatorID)
+		}
+
+		validator := validators.GetByID(vote.ValidatorID)
+		if validator == nil {
+			return fmt.Errorf("precommit from unknown validator %s", vote.ValidatorID)
+		}
+		if !vote.Verify(validator.PubKey) {
+			return fmt.Errorf("invalid signature on precommit from %s", vote.ValidatorID)
+		}
+
+		signed[vote.ValidatorID] = true
+		power += validator.VotingPower
+	}
+
+	if !validators.HasTwoThirdsMajority(power) {
+		return fmt.Errorf("commit has %d voting power, quorum is %d", power, validators.Quorum())
+	}
+	return nil
+}
+
+// VerifyCommit checks that cert finalizes block under the given validator set.
+func VerifyCommit(block *transaction.Block, cert *CommitCertificate, validators *ValidatorSet) error {
+	if cert.Height != int64(block.Index) || cert.BlockHash != block.Hash {
+		return fmt.Errorf("commit certificate for %d/%s does not match block %d/%s",
+			cert.Height, cert.BlockHash, block.Index, block.Hash)
+	}
+	return cert.Verify(validators)
+}
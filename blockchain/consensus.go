@@ -1,54 +1,830 @@
 package blockchain
 
 import (
+	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
-	"math/rand"
-	"matrix-blockchain/staking"
+	"matrix-blockchain/network"
+	"matrix-blockchain/transaction"
+	"matrix-blockchain/utils"
+	"sync"
+	"time"
 )
 
+// RoundStep is the step of the consensus state machine within a round.
+type RoundStep string
+
+const (
+	StepNewHeight RoundStep = "new-height" // Waiting to start round 0, e.g. for transactions
+	StepPropose   RoundStep = "propose"
+	StepPrevote   RoundStep = "prevote"
+	StepPrecommit RoundStep = "precommit"
+	StepCommit    RoundStep = "commit"
+)
+
+// maxFutureMessages bounds the messages buffered for the next height.
+const maxFutureMessages = 10000
+
+// MaxClockDrift is how far a new proposal's timestamp may be from local time
+// for us to prevote for it.
+const MaxClockDrift = 30 * time.Second
+
+// commitRetryInterval is how long to wait before retrying a decided block
+// the application failed to commit.
+const commitRetryInterval = 1 * time.Second
+
+const (
+	DefaultTimeoutPropose   = 3 * time.Second
+	DefaultTimeoutPrevote   = 1 * time.Second
+	DefaultTimeoutPrecommit = 1 * time.Second
+	DefaultTimeoutDelta     = 500 * time.Millisecond
+	DefaultBlockInterval    = 5 * time.Second
+)
+
+// Application connects the consensus engine to the chain state.
+type Application interface {
+	// ProposeBlock builds the block this node proposes at the given height.
+	ProposeBlock(height int64, proposer string) (*transaction.Block, error)
+	// ValidateBlock checks a proposed block against the chain state.
+	ValidateBlock(block *transaction.Block) error
+	// CommitBlock applies and stores a block decided by consensus along with its commit certificate.
+	CommitBlock(block *transaction.Block, commit *CommitCertificate) error
+	// ValidatorSet returns the validators voting at the given height.
+	ValidatorSet(height int64) *ValidatorSet
+	// ReportEvidence receives proof that a validator signed conflicting votes.
+	ReportEvidence(evidence *DuplicateVoteEvidence)
+	// HasPendingTxs reports whether there are transactions waiting for a block.
+	HasPendingTxs() bool
+}
+
+// Broadcaster sends consensus messages to the other validators.
+type Broadcaster interface {
+	BroadcastPayload(msgType string, payload interface{}) error
+}
+
+// VoteStore persists every accepted vote so it can be replayed for audits.
+type VoteStore interface {
+	SaveVote(vote *Vote) error
+}
+
+// Consensus runs the Tendermint propose/prevote/precommit state machine.
+// Blocks are committed once more than 2/3 of the voting power precommits them.
 type Consensus struct {
-	Validators []staking.Validator
-	Quorum     int
-	BlockHash  string
-	Votes      map[string]bool // Map of validator ID and their vote (yes/no)
+	mutex       sync.Mutex
+	app         Application
+	broadcaster Broadcaster
+	voteStore   VoteStore
+	wal         *WAL
+	privateKey  *ecdsa.PrivateKey // Consensus key signing our proposals and votes, nil for non-validators
+	validatorID string            // Our validator address in the current set, empty if we don't vote
+
+	Height     int64
+	Round      int
+	Step       RoundStep
+	Validators *ValidatorSet
+
+	heightStarted time.Time // When we entered the current height
+	ticker        timeoutTicker
+
+	proposals  map[int]*Proposal // Proposals received this height by round
+	prevotes   map[int]*VoteSet
+	precommits map[int]*VoteSet
+	validity   map[string]error // Cached ValidateBlock results by block hash
+
+	futureProposals []*Proposal // Proposals for the next height, replayed when we reach it
+	futureVotes     []*Vote     // Votes for the next height, replayed when we reach it
+
+	LockedRound int                // Round we locked on, -1 if unlocked
+	LockedBlock *transaction.Block // Block we are locked on
+	ValidRound  int                // Last round with a 2/3 prevote majority for a block, -1 if none
+	ValidBlock  *transaction.Block // Block that received that majority
+
+	prevoteTimeoutScheduled   bool // Whether timeoutPrevote was scheduled this round
+	precommitTimeoutScheduled bool // Whether timeoutPrecommit was scheduled this round
+	polUpdated                bool // Whether the 2/3 prevote majority rule already fired this round
+
+	TimeoutPropose      time.Duration
+	TimeoutPrevote      time.Duration
+	TimeoutPrecommit    time.Duration
+	TimeoutDelta        time.Duration // Added to each step timeout per failed round
+	BlockInterval       time.Duration // Target time between the starts of consecutive heights
+	CreateEmptyBlocks   bool          // Propose blocks even when there are no transactions
+	EmptyBlocksInterval time.Duration // Without CreateEmptyBlocks, produce an empty block after this long; 0 waits for transactions
 }
 
-func NewConsensus(validators []staking.Validator, blockHash string) *Consensus {
+// NewConsensus creates a consensus engine. privateKey is this node's
+// consensus key and may be nil for nodes that only follow. wal may be nil,
+// in which case a restarted validator could sign conflicting votes.
+func NewConsensus(app Application, broadcaster Broadcaster, voteStore VoteStore, wal *WAL, privateKey *ecdsa.PrivateKey) *Consensus {
 	return &Consensus{
-		Validators: validators,
-		Quorum:     len(validators) * 2 / 3, // 2/3 majority for consensus
-		BlockHash:  blockHash,
-		Votes:      make(map[string]bool),
+		app:               app,
+		broadcaster:       broadcaster,
+		voteStore:         voteStore,
+		wal:               wal,
+		privateKey:        privateKey,
+		TimeoutPropose:    DefaultTimeoutPropose,
+		TimeoutPrevote:    DefaultTimeoutPrevote,
+		TimeoutPrecommit:  DefaultTimeoutPrecommit,
+		TimeoutDelta:      DefaultTimeoutDelta,
+		BlockInterval:     DefaultBlockInterval,
+		CreateEmptyBlocks: true,
+	}
+}
+
+// Start begins consensus at the given height, first replaying the messages
+// recorded in the write-ahead log before a restart and restoring the lock
+// they imply. If the log can't be replayed consensus doesn't start, since
+// without it we could sign votes conflicting with those we signed before
+// the restart.
+func (c *Consensus) Start(height int64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.newHeight(height)
+	round, err := c.replayWAL()
+	if err != nil {
+		return fmt.Errorf("failed to replay consensus WAL: %v", err)
+	}
+	if err := c.restoreLock(); err != nil {
+		return fmt.Errorf("failed to restore consensus lock: %v", err)
+	}
+	if round > 0 {
+		c.startRound(round)
+	} else {
+		c.enterHeight()
 	}
+	c.process()
+	return nil
 }
 
-func (c *Consensus) StartVoting() {
-	// Randomly pick a subset of validators to vote on the block
-	for _, validator := range c.Validators {
-		// Simulate a vote decision based on validator's stake
-		if rand.Float32() < float32(validator.StakedAmount)/10000 {
-			c.Votes[validator.ID] = true
-		} else {
-			c.Votes[validator.ID] = false
+// TxsAvailable notifies consensus that transactions arrived, starting the
+// height if it was waiting for them.
+func (c *Consensus) TxsAvailable() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.Step == StepNewHeight {
+		c.startRound(0)
+		c.process()
+	}
+}
+
+// replayWAL restores the messages of the current height from the write-ahead
+// log and returns the latest round we signed anything in.
+func (c *Consensus) replayWAL() (int, error) {
+	entries, err := c.wal.ReadAll()
+	if err != nil {
+		return 0, err
+	}
+
+	round := 0
+	for _, entry := range entries {
+		if entry.Height != c.Height && entry.Height != c.Height+1 {
+			continue
+		}
+		switch entry.Type {
+		case walProposal, walSignedProposal:
+			if entry.Proposal == nil {
+				continue
+			}
+			c.addProposal(entry.Proposal)
+			if entry.Type == walSignedProposal && entry.Height == c.Height && entry.Proposal.Round > round {
+				round = entry.Proposal.Round
+			}
+		case walVote, walSignedVote:
+			if entry.Vote == nil {
+				continue
+			}
+			c.addVote(entry.Vote)
+			if entry.Type == walSignedVote && entry.Height == c.Height && entry.Vote.Round > round {
+				round = entry.Vote.Round
+			}
 		}
 	}
+	if len(entries) > 0 {
+		fmt.Printf("Replayed %d consensus WAL entries, resuming height %d at round %d\n", len(entries), c.Height, round)
+	}
+	return round, nil
+}
+
+// restoreLock rebuilds the locked and valid blocks from the replayed
+// messages. The state machine only applies those rules in the current
+// round, so replaying earlier rounds doesn't set them. We lock on a block
+// exactly when we precommit it, so the lock is the block of our latest
+// precommit for a block. The valid block is the latest proposed block with
+// a 2/3 prevote majority.
+func (c *Consensus) restoreLock() error {
+	for round, precommits := range c.precommits {
+		own := precommits.votes[c.validatorID]
+		if own == nil || own.BlockHash == "" || round <= c.LockedRound {
+			continue
+		}
+		proposal := c.proposals[round]
+		if proposal == nil || proposal.Block.Hash != own.BlockHash {
+			return fmt.Errorf("block %s we precommitted in round %d is missing", own.BlockHash, round)
+		}
+		c.LockedRound, c.LockedBlock = round, proposal.Block
+	}
+
+	for round, prevotes := range c.prevotes {
+		hash, ok := prevotes.TwoThirdsMajority()
+		proposal := c.proposals[round]
+		if !ok || hash == "" || round <= c.ValidRound || proposal == nil || proposal.Block.Hash != hash {
+			continue
+		}
+		if c.isValid(proposal.Block) {
+			c.ValidRound, c.ValidBlock = round, proposal.Block
+		}
+	}
+	return nil
+}
+
+// HandleMessage processes a consensus message received from a peer.
+func (c *Consensus) HandleMessage(msg network.Message) error {
+	switch msg.Type {
+	case network.MsgProposal:
+		var proposal Proposal
+		if err := json.Unmarshal(msg.Payload, &proposal); err != nil {
+			return fmt.Errorf("invalid proposal: %v", err)
+		}
+		return c.AddProposal(&proposal)
+	case network.MsgVote:
+		var vote Vote
+		if err := json.Unmarshal(msg.Payload, &vote); err != nil {
+			return fmt.Errorf("invalid vote: %v", err)
+		}
+		return c.AddVote(&vote)
+	default:
+		return fmt.Errorf("unexpected consensus message: %s", msg.Type)
+	}
+}
+
+// AddProposal records a signed proposal from the round's proposer.
+func (c *Consensus) AddProposal(proposal *Proposal) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.wal.Write(WALEntry{Type: walProposal, Height: proposal.Height, Proposal: proposal}, false); err != nil {
+		return err
+	}
+	if err := c.addProposal(proposal); err != nil {
+		return err
+	}
+	c.process()
+	return nil
 }
 
-func (c *Consensus) IsConsensusAchieved() bool {
-	votesFor := 0
-	for _, vote := range c.Votes {
-		if vote {
-			votesFor++
+// AddVote records a signed prevote or precommit.
+func (c *Consensus) AddVote(vote *Vote) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.wal.Write(WALEntry{Type: walVote, Height: vote.Height, Vote: vote}, false); err != nil {
+		return err
+	}
+	if err := c.addVote(vote); err != nil {
+		return err
+	}
+	c.process()
+	return nil
+}
+
+// AddCommit commits a block the rest of the network decided at the current
+// height, proven by its commit certificate. Nodes that fell behind catch up
+// with the committed blocks their peers send them, one height at a time, and
+// move on to the next height straight away.
+func (c *Consensus) AddCommit(block *transaction.Block, cert *CommitCertificate) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if cert.Height != c.Height || int64(block.Index) != c.Height {
+		return fmt.Errorf("committed block for height %d, current height is %d", block.Index, c.Height)
+	}
+	if c.Step == StepCommit {
+		return nil // Already decided, the block is committed or being retried
+	}
+	if !c.isValid(block) {
+		return fmt.Errorf("committed block %s is invalid", block.Hash)
+	}
+	if err := c.app.CommitBlock(block, cert); err != nil {
+		return err
+	}
+	fmt.Printf("Caught up with block %s at height %d\n", block.Hash, c.Height)
+
+	c.resetWAL()
+	c.newHeight(c.Height + 1)
+	c.enterHeight()
+	c.process()
+	return nil
+}
+
+func (c *Consensus) addProposal(proposal *Proposal) error {
+	if proposal.Height == c.Height+1 && len(c.futureProposals) < maxFutureMessages {
+		c.futureProposals = append(c.futureProposals, proposal)
+		return nil
+	}
+	if proposal.Height != c.Height {
+		return fmt.Errorf("proposal for height %d, current height is %d", proposal.Height, c.Height)
+	}
+	if proposal.Round < 0 {
+		return fmt.Errorf("invalid proposal round %d", proposal.Round)
+	}
+	if _, exists := c.proposals[proposal.Round]; exists {
+		return nil
+	}
+
+	proposer := c.Validators.Proposer(proposal.Height, proposal.Round)
+	if proposer == nil || proposer.ID != proposal.ProposerID {
+		return fmt.Errorf("proposal from %s is not from the round's proposer", proposal.ProposerID)
+	}
+	if !proposal.Verify(proposer.PubKey) {
+		return fmt.Errorf("invalid signature on proposal from %s", proposal.ProposerID)
+	}
+	if proposal.POLRound < -1 || proposal.POLRound >= proposal.Round {
+		return fmt.Errorf("invalid proof-of-lock round %d", proposal.POLRound)
+	}
+
+	c.proposals[proposal.Round] = proposal
+	return nil
+}
+
+func (c *Consensus) addVote(vote *Vote) error {
+	if vote.Height == c.Height+1 && len(c.futureVotes) < maxFutureMessages {
+		c.futureVotes = append(c.futureVotes, vote)
+		return nil
+	}
+	if vote.Height != c.Height {
+		return fmt.Errorf("vote for height %d, current height is %d", vote.Height, c.Height)
+	}
+	if vote.Round < 0 {
+		return fmt.Errorf("invalid vote round %d", vote.Round)
+	}
+
+	votes := c.voteSet(vote.Round, vote.Type)
+	if votes == nil {
+		return fmt.Errorf("unknown vote type: %s", vote.Type)
+	}
+	added, err := votes.AddVote(vote)
+	if conflict, ok := err.(*ConflictingVoteError); ok {
+		c.app.ReportEvidence(conflict.Evidence)
+	}
+	if err != nil || !added || c.voteStore == nil {
+		return err
+	}
+	if err := c.voteStore.SaveVote(vote); err != nil {
+		fmt.Printf("Failed to persist vote from %s: %v\n", vote.ValidatorID, err)
+	}
+	return nil
+}
+
+// voteSet returns the vote set for a round, creating it on first use.
+func (c *Consensus) voteSet(round int, voteType VoteType) *VoteSet {
+	var sets map[int]*VoteSet
+	switch voteType {
+	case Prevote:
+		sets = c.prevotes
+	case Precommit:
+		sets = c.precommits
+	default:
+		return nil
+	}
+
+	if sets[round] == nil {
+		sets[round] = NewVoteSet(c.Height, round, voteType, c.Validators)
+	}
+	return sets[round]
+}
+
+// newHeight resets the round state for a new height.
+func (c *Consensus) newHeight(height int64) {
+	c.ticker.stop()
+	c.Height = height
+	c.Round = 0
+	c.Step = StepNewHeight
+	c.heightStarted = time.Now()
+	c.Validators = c.app.ValidatorSet(height)
+	c.validatorID = ""
+	if c.privateKey != nil {
+		if self := c.Validators.GetByPubKey(utils.EncodePublicKey(&c.privateKey.PublicKey)); self != nil {
+			c.validatorID = self.ID
 		}
 	}
-	return votesFor >= c.Quorum
+	c.proposals = make(map[int]*Proposal)
+	c.prevotes = make(map[int]*VoteSet)
+	c.precommits = make(map[int]*VoteSet)
+	c.validity = make(map[string]error)
+	c.LockedRound, c.LockedBlock = -1, nil
+	c.ValidRound, c.ValidBlock = -1, nil
+
+	// Replay messages that arrived before we reached this height
+	proposals, votes := c.futureProposals, c.futureVotes
+	c.futureProposals, c.futureVotes = nil, nil
+	for _, proposal := range proposals {
+		if proposal.Height == height {
+			c.addProposal(proposal)
+		}
+	}
+	for _, vote := range votes {
+		if vote.Height == height {
+			c.addVote(vote)
+		}
+	}
+}
+
+// enterHeight starts round 0 of the current height unless empty blocks are
+// disabled and there is nothing to put in a block. In that case we wait for
+// transactions, for another validator to start the round, or for the empty
+// blocks interval to pass.
+func (c *Consensus) enterHeight() {
+	if c.CreateEmptyBlocks || c.app.HasPendingTxs() || c.hasMessages() {
+		c.startRound(0)
+		return
+	}
+	if c.EmptyBlocksInterval > 0 {
+		c.scheduleTimeout(StepNewHeight, c.EmptyBlocksInterval)
+	}
 }
 
-func (c *Consensus) FinalizeBlock() bool {
-	if c.IsConsensusAchieved() {
-		fmt.Println("Consensus reached, block finalized.")
+// hasMessages reports whether any proposal or vote was received this height.
+func (c *Consensus) hasMessages() bool {
+	if len(c.proposals) > 0 {
 		return true
 	}
-	fmt.Println("Consensus not reached, block rejected.")
+	for _, sets := range []map[int]*VoteSet{c.prevotes, c.precommits} {
+		for _, set := range sets {
+			if len(set.votes) > 0 {
+				return true
+			}
+		}
+	}
 	return false
 }
+
+// stepTimeout returns the timeout of a step in a round. Timeouts grow with
+// each failed round so a slow network eventually gets enough time to decide.
+func (c *Consensus) stepTimeout(step RoundStep, round int) time.Duration {
+	base := c.TimeoutPropose
+	switch step {
+	case StepPrevote:
+		base = c.TimeoutPrevote
+	case StepPrecommit:
+		base = c.TimeoutPrecommit
+	}
+	return base + time.Duration(round)*c.TimeoutDelta
+}
+
+// startRound enters the propose step of a round, proposing a block if we are the proposer.
+func (c *Consensus) startRound(round int) {
+	c.Round = round
+	c.Step = StepPropose
+	c.prevoteTimeoutScheduled = false
+	c.precommitTimeoutScheduled = false
+	c.polUpdated = false
+
+	proposer := c.Validators.Proposer(c.Height, round)
+	if proposer == nil || c.validatorID == "" || proposer.ID != c.validatorID {
+		c.scheduleTimeout(StepPropose, c.stepTimeout(StepPropose, c.Round))
+		return
+	}
+
+	// Resend the proposal we signed for this round before a restart
+	if proposal := c.proposals[round]; proposal != nil {
+		if err := c.broadcaster.BroadcastPayload(network.MsgProposal, proposal); err != nil {
+			fmt.Printf("Failed to broadcast proposal: %v\n", err)
+		}
+		return
+	}
+
+	block := c.ValidBlock
+	if block == nil {
+		var err error
+		block, err = c.app.ProposeBlock(c.Height, c.validatorID)
+		if err != nil {
+			fmt.Printf("Failed to build proposal at height %d: %v\n", c.Height, err)
+			c.scheduleTimeout(StepPropose, c.stepTimeout(StepPropose, c.Round))
+			return
+		}
+	}
+
+	proposal := &Proposal{
+		Height:     c.Height,
+		Round:      round,
+		POLRound:   c.ValidRound,
+		Block:      block,
+		ProposerID: c.validatorID,
+	}
+	if err := proposal.Sign(c.privateKey); err != nil {
+		fmt.Printf("Failed to sign proposal: %v\n", err)
+		return
+	}
+	if err := c.wal.Write(WALEntry{Type: walSignedProposal, Height: c.Height, Proposal: proposal}, true); err != nil {
+		fmt.Printf("Failed to record own proposal: %v\n", err)
+		return
+	}
+
+	c.proposals[round] = proposal
+	if err := c.broadcaster.BroadcastPayload(network.MsgProposal, proposal); err != nil {
+		fmt.Printf("Failed to broadcast proposal: %v\n", err)
+	}
+}
+
+// process applies the state machine rules until no further transition is possible.
+func (c *Consensus) process() {
+	if c.Validators == nil {
+		return // Not started yet, messages are buffered until Start
+	}
+	if c.Step == StepNewHeight {
+		if !c.hasMessages() {
+			return
+		}
+		c.startRound(0) // Another validator started the height
+	}
+	for c.Step != StepCommit && c.applyRules() {
+	}
+}
+
+// applyRules applies the first matching rule and reports whether the state changed.
+func (c *Consensus) applyRules() bool {
+	// A 2/3 precommit majority for a block we have commits it, in any round
+	for _, precommits := range c.precommits {
+		hash, ok := precommits.TwoThirdsMajority()
+		if !ok || hash == "" {
+			continue
+		}
+		if block := c.blockForHash(hash); block != nil && c.isValid(block) {
+			c.commit(block, NewCommitCertificate(precommits, hash))
+			return true
+		}
+	}
+
+	// More than 1/3 of the voting power is in a later round, catch up
+	for round := range c.roundsAhead() {
+		if c.hasOneThirdInRound(round) {
+			c.startRound(round)
+			return true
+		}
+	}
+
+	proposal := c.proposals[c.Round]
+	prevotes := c.voteSet(c.Round, Prevote)
+	precommits := c.voteSet(c.Round, Precommit)
+
+	if c.Step == StepPropose && proposal != nil {
+		block := proposal.Block
+		if proposal.POLRound == -1 {
+			if c.isValid(block) && c.isTimely(block) && (c.LockedRound == -1 || c.LockedBlock.Hash == block.Hash) {
+				c.signVote(Prevote, block.Hash)
+			} else {
+				c.signVote(Prevote, "")
+			}
+			c.Step = StepPrevote
+			return true
+		}
+
+		hash, ok := c.voteSet(proposal.POLRound, Prevote).TwoThirdsMajority()
+		if ok && hash == block.Hash {
+			if c.isValid(block) && (c.LockedRound <= proposal.POLRound || c.LockedBlock.Hash == block.Hash) {
+				c.signVote(Prevote, block.Hash)
+			} else {
+				c.signVote(Prevote, "")
+			}
+			c.Step = StepPrevote
+			return true
+		}
+	}
+
+	if c.Step == StepPrevote && prevotes.HasTwoThirdsAny() && !c.prevoteTimeoutScheduled {
+		c.prevoteTimeoutScheduled = true
+		c.scheduleTimeout(StepPrevote, c.stepTimeout(StepPrevote, c.Round))
+	}
+
+	hash, ok := prevotes.TwoThirdsMajority()
+	if ok && hash != "" && proposal != nil && proposal.Block.Hash == hash &&
+		(c.Step == StepPrevote || c.Step == StepPrecommit) && !c.polUpdated && c.isValid(proposal.Block) {
+		c.polUpdated = true
+		if c.Step == StepPrevote {
+			c.LockedRound, c.LockedBlock = c.Round, proposal.Block
+			c.signVote(Precommit, hash)
+			c.Step = StepPrecommit
+		}
+		c.ValidRound, c.ValidBlock = c.Round, proposal.Block
+		return true
+	}
+
+	if c.Step == StepPrevote && ok && hash == "" {
+		c.signVote(Precommit, "")
+		c.Step = StepPrecommit
+		return true
+	}
+
+	if precommits.HasTwoThirdsAny() && !c.precommitTimeoutScheduled {
+		c.precommitTimeoutScheduled = true
+		c.scheduleTimeout(StepPrecommit, c.stepTimeout(StepPrecommit, c.Round))
+	}
+
+	return false
+}
+
+// roundsAhead returns the rounds after the current one that we have votes for.
+func (c *Consensus) roundsAhead() map[int]bool {
+	rounds := make(map[int]bool)
+	for round := range c.prevotes {
+		if round > c.Round {
+			rounds[round] = true
+		}
+	}
+	for round := range c.precommits {
+		if round > c.Round {
+			rounds[round] = true
+		}
+	}
+	return rounds
+}
+
+// hasOneThirdInRound reports whether validators holding more than 1/3 of the
+// voting power have sent any vote for the round.
+func (c *Consensus) hasOneThirdInRound(round int) bool {
+	voters := make(map[string]bool)
+	for _, sets := range []map[int]*VoteSet{c.prevotes, c.precommits} {
+		if set := sets[round]; set != nil {
+			for id := range set.votes {
+				voters[id] = true
+			}
+		}
+	}
+
+	var power int64
+	for id := range voters {
+		power += c.Validators.GetByID(id).VotingPower
+	}
+	return c.Validators.HasOneThird(power)
+}
+
+// blockForHash returns a proposed block with the given hash, if we received one.
+func (c *Consensus) blockForHash(hash string) *transaction.Block {
+	for _, proposal := range c.proposals {
+		if proposal.Block.Hash == hash {
+			return proposal.Block
+		}
+	}
+	return nil
+}
+
+// isValid validates a block with the application, caching the result.
+func (c *Consensus) isValid(block *transaction.Block) bool {
+	err, checked := c.validity[block.Hash]
+	if !checked {
+		err = c.app.ValidateBlock(block)
+		c.validity[block.Hash] = err
+		if err != nil {
+			fmt.Printf("Rejected block %s at height %d: %v\n", block.Hash, c.Height, err)
+		}
+	}
+	return err == nil
+}
+
+// isTimely reports whether a block's timestamp is within MaxClockDrift of
+// local time. It is only checked before prevoting for a new proposal: a
+// re-proposed block already had the prevotes of validators that checked it,
+// and a decided block is committed whatever our clock says.
+func (c *Consensus) isTimely(block *transaction.Block) bool {
+	drift := time.Duration(block.Timestamp-time.Now().Unix()) * time.Second
+	if drift > MaxClockDrift || drift < -MaxClockDrift {
+		fmt.Printf("Rejected block %s at height %d: timestamp is %v from local time\n", block.Hash, c.Height, drift)
+		return false
+	}
+	return true
+}
+
+// signVote signs a vote for the current round, records it and broadcasts it.
+// Nodes without a validator key don't vote. If we already signed a vote of
+// this type in the round, e.g. before a restart, that vote is resent instead
+// so we never sign conflicting votes.
+func (c *Consensus) signVote(voteType VoteType, blockHash string) {
+	if c.validatorID == "" {
+		return
+	}
+	if existing := c.voteSet(c.Round, voteType).votes[c.validatorID]; existing != nil {
+		if err := c.broadcaster.BroadcastPayload(network.MsgVote, existing); err != nil {
+			fmt.Printf("Failed to broadcast %s: %v\n", voteType, err)
+		}
+		return
+	}
+
+	vote := &Vote{
+		Type:        voteType,
+		Height:      c.Height,
+		Round:       c.Round,
+		BlockHash:   blockHash,
+		ValidatorID: c.validatorID,
+		Timestamp:   time.Now().Unix(),
+	}
+	if err := vote.Sign(c.privateKey); err != nil {
+		fmt.Printf("Failed to sign %s: %v\n", voteType, err)
+		return
+	}
+	if err := c.wal.Write(WALEntry{Type: walSignedVote, Height: c.Height, Vote: vote}, true); err != nil {
+		fmt.Printf("Failed to record own %s: %v\n", voteType, err)
+		return
+	}
+
+	if err := c.addVote(vote); err != nil {
+		fmt.Printf("Failed to record own %s: %v\n", voteType, err)
+		return
+	}
+	if err := c.broadcaster.BroadcastPayload(network.MsgVote, vote); err != nil {
+		fmt.Printf("Failed to broadcast %s: %v\n", voteType, err)
+	}
+}
+
+// commit hands the decided block to the application and schedules the next
+// height to start BlockInterval after the current one started. A block the
+// application fails to commit is retried until it succeeds.
+func (c *Consensus) commit(block *transaction.Block, cert *CommitCertificate) {
+	c.Step = StepCommit
+	if err := c.app.CommitBlock(block, cert); err != nil {
+		fmt.Printf("Failed to commit block %s at height %d, retrying: %v\n", block.Hash, c.Height, err)
+		height := c.Height
+		c.ticker.schedule(commitRetryInterval, func() {
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+
+			if c.Height == height && c.Step == StepCommit {
+				c.commit(block, cert)
+			}
+		})
+		return
+	}
+	fmt.Printf("Committed block %s at height %d, round %d\n", block.Hash, c.Height, cert.Round)
+
+	height := c.Height + 1
+	delay := c.BlockInterval - time.Since(c.heightStarted)
+	if delay < 0 {
+		delay = 0
+	}
+	c.ticker.schedule(delay, func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		c.resetWAL()
+		c.newHeight(height)
+		c.enterHeight()
+		c.process()
+	})
+}
+
+// resetWAL drops the committed height from the write-ahead log, keeping the
+// messages already received for the next height.
+func (c *Consensus) resetWAL() {
+	var entries []WALEntry
+	for _, proposal := range c.futureProposals {
+		entries = append(entries, WALEntry{Type: walProposal, Height: proposal.Height, Proposal: proposal})
+	}
+	for _, vote := range c.futureVotes {
+		entries = append(entries, WALEntry{Type: walVote, Height: vote.Height, Vote: vote})
+	}
+	if err := c.wal.Reset(entries); err != nil {
+		fmt.Printf("Failed to reset consensus WAL: %v\n", err)
+	}
+}
+
+// scheduleTimeout fires a timeout for the given step of the current height and round.
+func (c *Consensus) scheduleTimeout(step RoundStep, duration time.Duration) {
+	height, round := c.Height, c.Round
+	c.ticker.schedule(duration, func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		c.onTimeout(height, round, step)
+		c.process()
+	})
+}
+
+// onTimeout moves the state machine forward when a step times out.
+// Stale timeouts from earlier heights, rounds or steps are ignored.
+func (c *Consensus) onTimeout(height int64, round int, step RoundStep) {
+	if height != c.Height || round != c.Round {
+		return
+	}
+
+	switch step {
+	case StepNewHeight:
+		if c.Step == StepNewHeight {
+			c.startRound(0)
+		}
+	case StepPropose:
+		if c.Step == StepPropose {
+			c.signVote(Prevote, "")
+			c.Step = StepPrevote
+		}
+	case StepPrevote:
+		if c.Step == StepPrevote {
+			c.signVote(Precommit, "")
+			c.Step = StepPrecommit
+		}
+	case StepPrecommit:
+		if c.Step != StepCommit {
+			c.startRound(round + 1)
+		}
+	}
+}
@@ -0,0 +1,433 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"matrix-blockchain/transaction"
+	"matrix-blockchain/utils"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testApp is an Application with a fixed validator set that records commits.
+type testApp struct {
+	validators *ValidatorSet
+	invalid    map[string]bool // Hashes of blocks ValidateBlock rejects
+	proposed   int
+	committed  []*transaction.Block
+	failCommit bool
+}
+
+func (a *testApp) ProposeBlock(height int64, proposer string) (*transaction.Block, error) {
+	a.proposed++
+	return newTestBlock(fmt.Sprintf("%s-%d", proposer, a.proposed)), nil
+}
+
+func (a *testApp) ValidateBlock(block *transaction.Block) error {
+	if a.invalid[block.Hash] {
+		return errors.New("invalid block")
+	}
+	return nil
+}
+
+func (a *testApp) CommitBlock(block *transaction.Block, commit *CommitCertificate) error {
+	if a.failCommit {
+		return errors.New("disk full")
+	}
+	if err := commit.Verify(a.validators); err != nil {
+		return err
+	}
+	a.committed = append(a.committed, block)
+	return nil
+}
+
+func (a *testApp) ValidatorSet(height int64) *ValidatorSet        { return a.validators }
+func (a *testApp) ReportEvidence(evidence *DuplicateVoteEvidence) {}
+func (a *testApp) HasPendingTxs() bool                            { return false }
+
+// testBroadcaster records the votes a node sends.
+type testBroadcaster struct {
+	votes []*Vote
+}
+
+func (b *testBroadcaster) BroadcastPayload(msgType string, payload interface{}) error {
+	if vote, ok := payload.(*Vote); ok {
+		b.votes = append(b.votes, vote)
+	}
+	return nil
+}
+
+// lastVote returns the last vote of a type the node sent in a round.
+func (b *testBroadcaster) lastVote(voteType VoteType, round int) *Vote {
+	for i := len(b.votes) - 1; i >= 0; i-- {
+		if b.votes[i].Type == voteType && b.votes[i].Round == round {
+			return b.votes[i]
+		}
+	}
+	return nil
+}
+
+// testNetwork is four validators of equal power at height 1, one of which
+// runs consensus. The node under test proposes in round 3, so the proposals
+// of rounds 0 to 2 come from the other validators.
+type testNetwork struct {
+	t           *testing.T
+	keys        map[string]*ecdsa.PrivateKey
+	validators  *ValidatorSet
+	self        string
+	app         *testApp
+	broadcaster *testBroadcaster
+	walPath     string
+	consensus   *Consensus
+}
+
+func newTestNetwork(t *testing.T) *testNetwork {
+	t.Helper()
+	keys := make(map[string]*ecdsa.PrivateKey)
+	validators := make([]*ConsensusValidator, 4)
+	for i := range validators {
+		key, pub := utils.GenerateKeys()
+		id := fmt.Sprintf("val%d", i)
+		keys[id] = key
+		validators[i] = &ConsensusValidator{ID: id, PubKey: utils.EncodePublicKey(pub), VotingPower: 10}
+	}
+	set := NewValidatorSet(validators, 1)
+
+	n := &testNetwork{
+		t:          t,
+		keys:       keys,
+		validators: set,
+		self:       set.Proposer(1, 3).ID,
+		app:        &testApp{validators: set, invalid: make(map[string]bool)},
+		walPath:    filepath.Join(t.TempDir(), "consensus.wal"),
+	}
+	for round := 0; round < 3; round++ {
+		if set.Proposer(1, round).ID == n.self {
+			t.Fatalf("node under test also proposes in round %d", round)
+		}
+	}
+	n.restart()
+	t.Cleanup(n.stop)
+	return n
+}
+
+// stop stops the node's consensus engine and closes its write-ahead log.
+func (n *testNetwork) stop() {
+	n.consensus.mutex.Lock()
+	defer n.consensus.mutex.Unlock()
+	n.consensus.ticker.stop()
+	n.consensus.wal.Close()
+}
+
+// restart starts a new consensus engine for the node from its write-ahead log.
+func (n *testNetwork) restart() {
+	n.t.Helper()
+	if n.consensus != nil {
+		n.stop()
+	}
+	wal, err := OpenWAL(n.walPath)
+	if err != nil {
+		n.t.Fatal(err)
+	}
+	n.broadcaster = &testBroadcaster{}
+	n.consensus = NewConsensus(n.app, n.broadcaster, nil, wal, n.keys[n.self])
+	// Timeouts are fired by the tests
+	n.consensus.TimeoutPropose = time.Hour
+	n.consensus.TimeoutPrevote = time.Hour
+	n.consensus.TimeoutPrecommit = time.Hour
+	n.consensus.BlockInterval = time.Hour
+	if err := n.consensus.Start(1); err != nil {
+		n.t.Fatal(err)
+	}
+}
+
+// newTestBlock returns a block at height 1 timestamped now.
+func newTestBlock(label string) *transaction.Block {
+	block := transaction.NewBlock(transaction.NewGenesisBlock(0), nil, nil, nil, "", label, time.Now().Unix())
+	return &block
+}
+
+// propose delivers the round's proposal from its proposer.
+func (n *testNetwork) propose(round, polRound int, block *transaction.Block) {
+	n.t.Helper()
+	proposer := n.validators.Proposer(1, round).ID
+	proposal := &Proposal{Height: 1, Round: round, POLRound: polRound, Block: block, ProposerID: proposer}
+	if err := proposal.Sign(n.keys[proposer]); err != nil {
+		n.t.Fatal(err)
+	}
+	if err := n.consensus.AddProposal(proposal); err != nil {
+		n.t.Fatal(err)
+	}
+}
+
+// vote delivers votes for a block, or nil when hash is empty, from the given
+// number of validators other than the node under test.
+func (n *testNetwork) vote(voteType VoteType, round int, hash string, voters int) {
+	n.t.Helper()
+	for _, v := range n.validators.Validators {
+		if voters == 0 {
+			return
+		}
+		if v.ID == n.self {
+			continue
+		}
+		vote := &Vote{Type: voteType, Height: 1, Round: round, BlockHash: hash, ValidatorID: v.ID}
+		if err := vote.Sign(n.keys[v.ID]); err != nil {
+			n.t.Fatal(err)
+		}
+		if err := n.consensus.AddVote(vote); err != nil {
+			n.t.Fatal(err)
+		}
+		voters--
+	}
+}
+
+// timeout fires a step timeout of the current round.
+func (n *testNetwork) timeout(step RoundStep) {
+	c := n.consensus
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onTimeout(c.Height, c.Round, step)
+	c.process()
+}
+
+// expectVote checks the node's vote of a type in a round.
+func (n *testNetwork) expectVote(voteType VoteType, round int, hash string) {
+	n.t.Helper()
+	vote := n.broadcaster.lastVote(voteType, round)
+	if vote == nil {
+		n.t.Fatalf("no %s in round %d", voteType, round)
+	}
+	if vote.BlockHash != hash {
+		n.t.Fatalf("%s in round %d for %q, want %q", voteType, round, vote.BlockHash, hash)
+	}
+}
+
+func TestLockSurvivesRestart(t *testing.T) {
+	n := newTestNetwork(t)
+	block := newTestBlock("B")
+
+	// Lock on B in round 0
+	n.propose(0, -1, block)
+	n.expectVote(Prevote, 0, block.Hash)
+	n.vote(Prevote, 0, block.Hash, 2)
+	n.expectVote(Precommit, 0, block.Hash)
+
+	// Round 1 passes without a proposal and we prevote nil
+	n.timeout(StepPrecommit)
+	n.timeout(StepPropose)
+	n.expectVote(Prevote, 1, "")
+
+	n.restart()
+	if n.consensus.Round != 1 {
+		t.Fatalf("resumed in round %d, want 1", n.consensus.Round)
+	}
+	if n.consensus.LockedRound != 0 || n.consensus.LockedBlock == nil || n.consensus.LockedBlock.Hash != block.Hash {
+		t.Fatalf("lock after restart is round %d, want round 0 on %s", n.consensus.LockedRound, block.Hash)
+	}
+	if n.consensus.ValidRound != 0 || n.consensus.ValidBlock == nil || n.consensus.ValidBlock.Hash != block.Hash {
+		t.Errorf("valid block after restart is from round %d, want round 0", n.consensus.ValidRound)
+	}
+
+	// A conflicting block in round 2 gets a nil prevote
+	n.timeout(StepPrecommit)
+	n.propose(2, -1, newTestBlock("B'"))
+	n.expectVote(Prevote, 2, "")
+}
+
+// certificate returns a commit certificate for a block at height 1 signed
+// by three validators other than the node under test.
+func (n *testNetwork) certificate(block *transaction.Block, round int) *CommitCertificate {
+	n.t.Helper()
+	precommits := NewVoteSet(1, round, Precommit, n.validators)
+	signers := 0
+	for _, v := range n.validators.Validators {
+		if v.ID == n.self || signers == 3 {
+			continue
+		}
+		vote := &Vote{Type: Precommit, Height: 1, Round: round, BlockHash: block.Hash, ValidatorID: v.ID}
+		if err := vote.Sign(n.keys[v.ID]); err != nil {
+			n.t.Fatal(err)
+		}
+		if _, err := precommits.AddVote(vote); err != nil {
+			n.t.Fatal(err)
+		}
+		signers++
+	}
+	return NewCommitCertificate(precommits, block.Hash)
+}
+
+func TestCommitOnPrecommitMajority(t *testing.T) {
+	n := newTestNetwork(t)
+	block := newTestBlock("B")
+
+	n.propose(0, -1, block)
+	n.expectVote(Prevote, 0, block.Hash)
+	n.vote(Prevote, 0, block.Hash, 2)
+	n.expectVote(Precommit, 0, block.Hash)
+	if len(n.app.committed) != 0 {
+		t.Fatal("committed before a precommit majority")
+	}
+
+	n.vote(Precommit, 0, block.Hash, 2)
+	if len(n.app.committed) != 1 || n.app.committed[0].Hash != block.Hash {
+		t.Fatalf("committed %d blocks, want block %s", len(n.app.committed), block.Hash)
+	}
+	if n.consensus.Step != StepCommit {
+		t.Errorf("step after commit is %s, want %s", n.consensus.Step, StepCommit)
+	}
+}
+
+func TestInvalidProposalGetsNilPrevote(t *testing.T) {
+	late := transaction.NewBlock(transaction.NewGenesisBlock(0), nil, nil, nil, "", "late", time.Now().Add(2*MaxClockDrift).Unix())
+	for _, tc := range []struct {
+		name    string
+		block   *transaction.Block
+		invalid bool
+	}{
+		{"invalid", newTestBlock("invalid"), true},
+		{"untimely", &late, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.app.invalid[tc.block.Hash] = tc.invalid
+			n.propose(0, -1, tc.block)
+			n.expectVote(Prevote, 0, "")
+		})
+	}
+}
+
+func TestLockedNodeRejectsOtherBlocks(t *testing.T) {
+	n := newTestNetwork(t)
+	locked := newTestBlock("B")
+	other := newTestBlock("B'")
+
+	n.propose(0, -1, locked)
+	n.vote(Prevote, 0, locked.Hash, 2)
+	n.expectVote(Precommit, 0, locked.Hash)
+	n.timeout(StepPrecommit)
+
+	// A new proposal for another block is refused while we are locked
+	n.propose(1, -1, other)
+	n.expectVote(Prevote, 1, "")
+
+	// Nor is a proposal claiming a proof-of-lock we haven't seen
+	n.timeout(StepPrevote)
+	n.timeout(StepPrecommit)
+	n.propose(2, 0, other)
+	if vote := n.broadcaster.lastVote(Prevote, 2); vote != nil {
+		t.Fatalf("prevoted %q for a proposal without its proof-of-lock", vote.BlockHash)
+	}
+	if n.consensus.LockedBlock.Hash != locked.Hash {
+		t.Error("lock moved to another block")
+	}
+}
+
+func TestNewerProofOfLockUnlocks(t *testing.T) {
+	n := newTestNetwork(t)
+	locked := newTestBlock("B")
+	other := newTestBlock("B'")
+
+	n.propose(0, -1, locked)
+	n.vote(Prevote, 0, locked.Hash, 2)
+	n.expectVote(Precommit, 0, locked.Hash)
+	n.timeout(StepPrecommit)
+
+	// We miss the round 1 proposal the others prevote for
+	n.timeout(StepPropose)
+	n.expectVote(Prevote, 1, "")
+	n.timeout(StepPrevote)
+	n.timeout(StepPrecommit)
+	n.vote(Prevote, 1, other.Hash, 3)
+
+	// Its proof-of-lock from round 1 is newer than our lock from round 0
+	n.propose(2, 1, other)
+	n.expectVote(Prevote, 2, other.Hash)
+}
+
+func TestTimeoutsMoveToTheNextRound(t *testing.T) {
+	n := newTestNetwork(t)
+	if n.consensus.Step != StepPropose {
+		t.Fatalf("step at the start of the height is %s, want %s", n.consensus.Step, StepPropose)
+	}
+
+	for _, tc := range []struct {
+		step  RoundStep
+		round int
+		want  RoundStep
+	}{
+		{StepPropose, 0, StepPrevote},
+		{StepPrevote, 0, StepPrecommit},
+		{StepPrecommit, 1, StepPropose},
+	} {
+		n.timeout(tc.step)
+		if n.consensus.Round != tc.round || n.consensus.Step != tc.want {
+			t.Fatalf("after the %s timeout at round %d step %s, want round %d step %s",
+				tc.step, n.consensus.Round, n.consensus.Step, tc.round, tc.want)
+		}
+	}
+	n.expectVote(Prevote, 0, "")
+	n.expectVote(Precommit, 0, "")
+
+	// A timeout from an earlier round is ignored
+	n.consensus.mutex.Lock()
+	n.consensus.onTimeout(1, 0, StepPrecommit)
+	n.consensus.mutex.Unlock()
+	if n.consensus.Round != 1 || n.consensus.Step != StepPropose {
+		t.Errorf("stale timeout moved consensus to round %d step %s", n.consensus.Round, n.consensus.Step)
+	}
+}
+
+func TestFailedCommitIsRetried(t *testing.T) {
+	n := newTestNetwork(t)
+	block := newTestBlock("B")
+	n.app.failCommit = true
+
+	n.propose(0, -1, block)
+	n.vote(Prevote, 0, block.Hash, 2)
+	n.vote(Precommit, 0, block.Hash, 2)
+	if n.consensus.Step != StepCommit || len(n.app.committed) != 0 {
+		t.Fatal("failed commit was not held for a retry")
+	}
+
+	n.consensus.mutex.Lock()
+	n.app.failCommit = false
+	n.consensus.mutex.Unlock()
+	deadline := time.Now().Add(5 * commitRetryInterval)
+	for time.Now().Before(deadline) {
+		n.consensus.mutex.Lock()
+		committed := len(n.app.committed)
+		n.consensus.mutex.Unlock()
+		if committed == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("decided block was not committed after the failure cleared")
+}
+
+func TestAddCommitCatchesUp(t *testing.T) {
+	n := newTestNetwork(t)
+	block := newTestBlock("B")
+
+	if err := n.consensus.AddCommit(block, &CommitCertificate{Height: 1, BlockHash: block.Hash}); err == nil {
+		t.Fatal("accepted a commit without precommits")
+	}
+	future := n.certificate(block, 0)
+	future.Height = 2
+	if err := n.consensus.AddCommit(block, future); err == nil {
+		t.Fatal("accepted a commit for another height")
+	}
+
+	if err := n.consensus.AddCommit(block, n.certificate(block, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.app.committed) != 1 || n.app.committed[0].Hash != block.Hash {
+		t.Fatalf("committed %d blocks, want block %s", len(n.app.committed), block.Hash)
+	}
+	if n.consensus.Height != 2 {
+		t.Errorf("height after catching up is %d, want 2", n.consensus.Height)
+	}
+}
@@ -1,74 +1,176 @@
 package blockchain
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
+	"matrix-blockchain/transaction"
+	"strconv"
 
 	"github.com/boltdb/bolt"
 )
 
 const (
-	blockchainDBFile = "blockchain.db"
 	blocksBucket     = "blocks"
+	heightsBucket    = "heights"
+	commitsBucket    = "commits"
+	votesBucket      = "votes"
+	receiptsBucket   = "receipts"
+	memoIndexBucket  = "memo_index"
+	validatorsBucket = "validator_sets"
+	stateBucket      = "state"
+	supplyBucket     = "supply"
 	latestBlockKey   = "latest"
+	finalizedKey     = "finalized"
+	stateHeightKey   = "height"
+	stateDataKey     = "data"
 )
 
+// buckets lists every bucket created when the database is opened.
+var buckets = []string{blocksBucket, heightsBucket, commitsBucket, votesBucket, receiptsBucket, memoIndexBucket, validatorsBucket, stateBucket, supplyBucket}
+
 // Database represents the blockchain database.
 type Database struct {
 	db *bolt.DB
 }
 
-// OpenDatabase opens or creates the blockchain database.
-func OpenDatabase() (*Database, error) {
-	db, err := bolt.Open(blockchainDBFile, 0600, nil)
+// OpenDatabase opens or creates the blockchain database at path.
+func OpenDatabase(path string) (*Database, error) {
+	db, err := bolt.Open(path, 0600, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
 
-	// Create the blocks bucket if it doesn't exist
+	// Create the buckets if they don't exist
 	err = db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(blocksBucket))
-		return err
+		for _, name := range buckets {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return fmt.Errorf("failed to create %s bucket: %v", name, err)
+			}
+		}
+		return nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create blocks bucket: %v", err)
+		return nil, err
 	}
 
 	return &Database{db: db}, nil
 }
 
-// SaveBlock stores a block in the database.
-func (db *Database) SaveBlock(block *Block) error {
+// heightKey encodes a block height so keys sort in height order.
+func heightKey(height int) []byte {
+	return []byte(fmt.Sprintf("%020d", height))
+}
+
+// finalizedHeight reads the height of the latest finalized block, 0 if only
+// the genesis block is stored.
+func finalizedHeight(tx *bolt.Tx) int64 {
+	bucket := tx.Bucket([]byte(commitsBucket))
+	if bucket == nil {
+		return 0
+	}
+	data := bucket.Get([]byte(finalizedKey))
+	if data == nil {
+		return 0
+	}
+	height, _ := strconv.ParseInt(string(data), 10, 64)
+	return height
+}
+
+// SaveBlock stores a block in the database and indexes it by height.
+// Finalized blocks are never replaced, so the chain can't be reorganized
+// below the finalized height.
+func (db *Database) SaveBlock(block *transaction.Block) error {
 	return db.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(blocksBucket))
-		if bucket == nil {
-			return fmt.Errorf("blocks bucket not found")
-		}
+		return saveBlock(tx, block)
+	})
+}
 
-		data, err := json.Marshal(block)
-		if err != nil {
-			return fmt.Errorf("failed to serialize block: %v", err)
-		}
+// saveBlock stores a block within a database transaction.
+func saveBlock(tx *bolt.Tx, block *transaction.Block) error {
+	bucket := tx.Bucket([]byte(blocksBucket))
+	heights := tx.Bucket([]byte(heightsBucket))
+	if bucket == nil || heights == nil {
+		return fmt.Errorf("blocks bucket not found")
+	}
 
-		err = bucket.Put([]byte(block.Hash), data)
-		if err != nil {
-			return fmt.Errorf("failed to save block: %v", err)
+	if finalized := finalizedHeight(tx); int64(block.Index) <= finalized {
+		existing := heights.Get(heightKey(block.Index))
+		if existing != nil && string(existing) == block.Hash {
+			return nil
 		}
-
-		// Update the latest block reference
-		err = bucket.Put([]byte(latestBlockKey), []byte(block.Hash))
-		if err != nil {
-			return fmt.Errorf("failed to update latest block: %v", err)
+		if existing != nil {
+			return fmt.Errorf("cannot replace block %d below finalized height %d", block.Index, finalized)
 		}
+	}
+
+	data, err := json.Marshal(block)
+	if err != nil {
+		return fmt.Errorf("failed to serialize block: %v", err)
+	}
 
+	err = bucket.Put([]byte(block.Hash), data)
+	if err != nil {
+		return fmt.Errorf("failed to save block: %v", err)
+	}
+
+	err = heights.Put(heightKey(block.Index), []byte(block.Hash))
+	if err != nil {
+		return fmt.Errorf("failed to index block height: %v", err)
+	}
+
+	// Update the latest block reference
+	err = bucket.Put([]byte(latestBlockKey), []byte(block.Hash))
+	if err != nil {
+		return fmt.Errorf("failed to update latest block: %v", err)
+	}
+
+	return nil
+}
+
+// CommittedBlock is everything stored for a block decided by consensus.
+type CommittedBlock struct {
+	Block      *transaction.Block
+	Commit     *CommitCertificate
+	Receipts   []transaction.Receipt
+	Supply     []byte        // Encoded supply change of the block
+	State      []byte        // Encoded chain state after the block
+	Validators *ValidatorSet // Validator set the block elected, nil if unchanged
+}
+
+// SaveCommittedBlock stores a decided block with its commit certificate,
+// receipts, supply change, the state snapshot after it and the validator set
+// it elected in a single database transaction, so a crash never leaves part
+// of a height stored.
+func (db *Database) SaveCommittedBlock(committed *CommittedBlock) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		height := int64(committed.Block.Index)
+		if err := saveBlock(tx, committed.Block); err != nil {
+			return err
+		}
+		if err := saveCommit(tx, committed.Commit); err != nil {
+			return err
+		}
+		if err := saveReceipts(tx, committed.Receipts); err != nil {
+			return err
+		}
+		if err := saveSupply(tx, height, committed.Supply); err != nil {
+			return err
+		}
+		if err := saveState(tx, height, committed.State); err != nil {
+			return err
+		}
+		if committed.Validators != nil {
+			return saveValidatorSet(tx, committed.Validators)
+		}
 		return nil
 	})
 }
 
 // GetBlock retrieves a block by its hash.
-func (db *Database) GetBlock(hash string) (*Block, error) {
-	var block *Block
+func (db *Database) GetBlock(hash string) (*transaction.Block, error) {
+	var block *transaction.Block
 
 	err := db.db.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(blocksBucket))
@@ -81,7 +183,7 @@ func (db *Database) GetBlock(hash string) (*Block, error) {
 			return fmt.Errorf("block not found")
 		}
 
-		block = &Block{}
+		block = &transaction.Block{}
 		err := json.Unmarshal(data, block)
 		if err != nil {
 			return fmt.Errorf("failed to deserialize block: %v", err)
@@ -97,8 +199,8 @@ func (db *Database) GetBlock(hash string) (*Block, error) {
 }
 
 // GetLatestBlock retrieves the latest block in the blockchain.
-func (db *Database) GetLatestBlock() (*Block, error) {
-	var latestBlock *Block
+func (db *Database) GetLatestBlock() (*transaction.Block, error) {
+	var latestBlock *transaction.Block
 
 	err := db.db.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(blocksBucket))
@@ -116,7 +218,7 @@ func (db *Database) GetLatestBlock() (*Block, error) {
 			return fmt.Errorf("latest block data not found")
 		}
 
-		latestBlock = &Block{}
+		latestBlock = &transaction.Block{}
 		err := json.Unmarshal(data, latestBlock)
 		if err != nil {
 			return fmt.Errorf("failed to deserialize latest block: %v", err)
@@ -131,6 +233,313 @@ func (db *Database) GetLatestBlock() (*Block, error) {
 	return latestBlock, nil
 }
 
+// GetBlockByHeight retrieves a block by its height.
+func (db *Database) GetBlockByHeight(height int) (*transaction.Block, error) {
+	var hash []byte
+
+	err := db.db.View(func(tx *bolt.Tx) error {
+		heights := tx.Bucket([]byte(heightsBucket))
+		if heights == nil {
+			return fmt.Errorf("heights bucket not found")
+		}
+
+		hash = heights.Get(heightKey(height))
+		if hash == nil {
+			return fmt.Errorf("no block at height %d", height)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return db.GetBlock(string(hash))
+}
+
+// storedValidatorSet is the persisted form of a validator set.
+type storedValidatorSet struct {
+	StartHeight int64                 `json:"start_height"`
+	Validators  []*ConsensusValidator `json:"validators"`
+}
+
+// SaveValidatorSet stores a validator set under the height it takes effect.
+func (db *Database) SaveValidatorSet(set *ValidatorSet) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return saveValidatorSet(tx, set)
+	})
+}
+
+// saveValidatorSet stores a validator set within a database transaction.
+func saveValidatorSet(tx *bolt.Tx, set *ValidatorSet) error {
+	bucket := tx.Bucket([]byte(validatorsBucket))
+	if bucket == nil {
+		return fmt.Errorf("validator sets bucket not found")
+	}
+
+	data, err := json.Marshal(storedValidatorSet{StartHeight: set.StartHeight, Validators: set.Validators})
+	if err != nil {
+		return fmt.Errorf("failed to serialize validator set: %v", err)
+	}
+
+	err = bucket.Put(heightKey(int(set.StartHeight)), data)
+	if err != nil {
+		return fmt.Errorf("failed to save validator set: %v", err)
+	}
+	return nil
+}
+
+// GetValidatorSet retrieves the validator set voting at a height: the stored
+// set with the greatest start height not above it.
+func (db *Database) GetValidatorSet(height int64) (*ValidatorSet, error) {
+	var stored storedValidatorSet
+
+	err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(validatorsBucket))
+		if bucket == nil {
+			return fmt.Errorf("validator sets bucket not found")
+		}
+
+		key := heightKey(int(height))
+		cursor := bucket.Cursor()
+		k, data := cursor.Seek(key)
+		if k == nil {
+			k, data = cursor.Last()
+		} else if string(k) != string(key) {
+			k, data = cursor.Prev()
+		}
+		if k == nil {
+			return fmt.Errorf("no validator set for height %d", height)
+		}
+
+		err := json.Unmarshal(data, &stored)
+		if err != nil {
+			return fmt.Errorf("failed to deserialize validator set: %v", err)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return NewValidatorSet(stored.Validators, stored.StartHeight), nil
+}
+
+// saveState stores the encoded chain state after the block at height,
+// replacing the previous snapshot.
+func saveState(tx *bolt.Tx, height int64, data []byte) error {
+	bucket := tx.Bucket([]byte(stateBucket))
+	if bucket == nil {
+		return fmt.Errorf("state bucket not found")
+	}
+
+	err := bucket.Put([]byte(stateDataKey), data)
+	if err != nil {
+		return fmt.Errorf("failed to save state: %v", err)
+	}
+	err = bucket.Put([]byte(stateHeightKey), []byte(strconv.FormatInt(height, 10)))
+	if err != nil {
+		return fmt.Errorf("failed to save state height: %v", err)
+	}
+	return nil
+}
+
+// GetState retrieves the latest chain state snapshot and the height it was taken at.
+func (db *Database) GetState() (int64, []byte, error) {
+	var height int64
+	var data []byte
+
+	err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(stateBucket))
+		if bucket == nil {
+			return fmt.Errorf("state bucket not found")
+		}
+
+		heightData := bucket.Get([]byte(stateHeightKey))
+		stored := bucket.Get([]byte(stateDataKey))
+		if heightData == nil || stored == nil {
+			return fmt.Errorf("no state snapshot found")
+		}
+
+		var err error
+		height, err = strconv.ParseInt(string(heightData), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid state height: %v", err)
+		}
+		data = append([]byte(nil), stored...)
+		return nil
+	})
+
+	if err != nil {
+		return 0, nil, err
+	}
+	return height, data, nil
+}
+
+// saveCommit stores the commit certificate of a finalized block and advances
+// the finalized height.
+func saveCommit(tx *bolt.Tx, cert *CommitCertificate) error {
+	bucket := tx.Bucket([]byte(commitsBucket))
+	if bucket == nil {
+		return fmt.Errorf("commits bucket not found")
+	}
+
+	data, err := json.Marshal(cert)
+	if err != nil {
+		return fmt.Errorf("failed to serialize commit: %v", err)
+	}
+
+	err = bucket.Put(heightKey(int(cert.Height)), data)
+	if err != nil {
+		return fmt.Errorf("failed to save commit: %v", err)
+	}
+
+	if cert.Height > finalizedHeight(tx) {
+		err = bucket.Put([]byte(finalizedKey), []byte(strconv.FormatInt(cert.Height, 10)))
+		if err != nil {
+			return fmt.Errorf("failed to update finalized height: %v", err)
+		}
+	}
+	return nil
+}
+
+// GetFinalizedHeight returns the height of the latest finalized block.
+func (db *Database) GetFinalizedHeight() (int64, error) {
+	var height int64
+	err := db.db.View(func(tx *bolt.Tx) error {
+		height = finalizedHeight(tx)
+		return nil
+	})
+	return height, err
+}
+
+// SaveSupply stores the encoded supply change of the block at a height.
+func (db *Database) SaveSupply(height int64, data []byte) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return saveSupply(tx, height, data)
+	})
+}
+
+// saveSupply stores a supply record within a database transaction.
+func saveSupply(tx *bolt.Tx, height int64, data []byte) error {
+	bucket := tx.Bucket([]byte(supplyBucket))
+	if bucket == nil {
+		return fmt.Errorf("supply bucket not found")
+	}
+
+	err := bucket.Put(heightKey(int(height)), data)
+	if err != nil {
+		return fmt.Errorf("failed to save supply: %v", err)
+	}
+	return nil
+}
+
+// GetSupply retrieves the encoded supply change of the block at a height.
+func (db *Database) GetSupply(height int64) ([]byte, error) {
+	var data []byte
+
+	err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(supplyBucket))
+		if bucket == nil {
+			return fmt.Errorf("supply bucket not found")
+		}
+
+		stored := bucket.Get(heightKey(int(height)))
+		if stored == nil {
+			return fmt.Errorf("no supply record for height %d", height)
+		}
+		data = append([]byte(nil), stored...)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// GetCommit retrieves the commit certificate of the block at a height.
+func (db *Database) GetCommit(height int) (*CommitCertificate, error) {
+	var cert *CommitCertificate
+
+	err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(commitsBucket))
+		if bucket == nil {
+			return fmt.Errorf("commits bucket not found")
+		}
+
+		data := bucket.Get(heightKey(height))
+		if data == nil {
+			return fmt.Errorf("no commit for height %d", height)
+		}
+
+		cert = &CommitCertificate{}
+		err := json.Unmarshal(data, cert)
+		if err != nil {
+			return fmt.Errorf("failed to deserialize commit: %v", err)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// voteKey orders votes by height, round, type and validator.
+func voteKey(vote *Vote) []byte {
+	return []byte(fmt.Sprintf("%s/%010d/%s/%s", heightKey(int(vote.Height)), vote.Round, vote.Type, vote.ValidatorID))
+}
+
+// SaveVote stores a signed vote so the votes of a height can be replayed for audits.
+func (db *Database) SaveVote(vote *Vote) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(votesBucket))
+		if bucket == nil {
+			return fmt.Errorf("votes bucket not found")
+		}
+
+		data, err := json.Marshal(vote)
+		if err != nil {
+			return fmt.Errorf("failed to serialize vote: %v", err)
+		}
+
+		err = bucket.Put(voteKey(vote), data)
+		if err != nil {
+			return fmt.Errorf("failed to save vote: %v", err)
+		}
+		return nil
+	})
+}
+
+// GetVotes retrieves all stored votes of a height ordered by round, type and validator.
+func (db *Database) GetVotes(height int) ([]*Vote, error) {
+	var votes []*Vote
+
+	err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(votesBucket))
+		if bucket == nil {
+			return fmt.Errorf("votes bucket not found")
+		}
+
+		prefix := append(heightKey(height), '/')
+		cursor := bucket.Cursor()
+		for key, data := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, data = cursor.Next() {
+			vote := &Vote{}
+			if err := json.Unmarshal(data, vote); err != nil {
+				return fmt.Errorf("failed to deserialize vote: %v", err)
+			}
+			votes = append(votes, vote)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return votes, nil
+}
+
 // Close closes the database connection.
 func (db *Database) Close() {
 	err := db.db.Close()
@@ -0,0 +1,156 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"matrix-blockchain/transaction"
+	"matrix-blockchain/utils"
+	"sync"
+)
+
+// EvidenceDuplicateVote is the evidence type for a validator signing two
+// different blocks in the same height, round and step.
+const EvidenceDuplicateVote = "duplicate-vote"
+
+// ConflictingVoteError is returned by VoteSet.AddVote when a validator has
+// signed two different votes for the same height, round and step.
+type ConflictingVoteError struct {
+	Evidence *DuplicateVoteEvidence
+}
+
+func (e *ConflictingVoteError) Error() string {
+	return "conflicting vote from validator " + e.Evidence.VoteA.ValidatorID
+}
+
+// DuplicateVoteEvidence proves that a validator equivocated.
+type DuplicateVoteEvidence struct {
+	VoteA *Vote `json:"vote_a"`
+	VoteB *Vote `json:"vote_b"`
+}
+
+// NewDuplicateVoteEvidence orders two conflicting votes so the same pair
+// always produces the same evidence.
+func NewDuplicateVoteEvidence(a, b *Vote) *DuplicateVoteEvidence {
+	if a.BlockHash > b.BlockHash {
+		a, b = b, a
+	}
+	return &DuplicateVoteEvidence{VoteA: a, VoteB: b}
+}
+
+// Verify checks that both votes are signed by the given key and conflict.
+func (ev *DuplicateVoteEvidence) Verify(pubKey []byte) error {
+	a, b := ev.VoteA, ev.VoteB
+	if a == nil || b == nil {
+		return errors.New("evidence is missing a vote")
+	}
+	if a.ValidatorID != b.ValidatorID {
+		return errors.New("evidence votes are from different validators")
+	}
+	if a.Height != b.Height || a.Round != b.Round || a.Type != b.Type {
+		return errors.New("evidence votes are for different heights, rounds or steps")
+	}
+	if a.BlockHash == b.BlockHash {
+		return errors.New("evidence votes do not conflict")
+	}
+	if !a.Verify(pubKey) || !b.Verify(pubKey) {
+		return errors.New("invalid signature on evidence vote")
+	}
+	return nil
+}
+
+// Hash identifies the evidence.
+func (ev *DuplicateVoteEvidence) Hash() string {
+	data, _ := json.Marshal(ev)
+	return utils.Hash(data)
+}
+
+// ToEvidence packages the evidence for inclusion in a block.
+func (ev *DuplicateVoteEvidence) ToEvidence() (transaction.Evidence, error) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return transaction.Evidence{}, fmt.Errorf("failed to encode evidence: %v", err)
+	}
+	return transaction.Evidence{
+		Type:        EvidenceDuplicateVote,
+		ValidatorID: ev.VoteA.ValidatorID,
+		Height:      ev.VoteA.Height,
+		Data:        data,
+	}, nil
+}
+
+// DecodeDuplicateVoteEvidence unpacks duplicate-vote evidence from a block.
+func DecodeDuplicateVoteEvidence(evidence transaction.Evidence) (*DuplicateVoteEvidence, error) {
+	if evidence.Type != EvidenceDuplicateVote {
+		return nil, fmt.Errorf("unknown evidence type: %s", evidence.Type)
+	}
+	var ev DuplicateVoteEvidence
+	if err := json.Unmarshal(evidence.Data, &ev); err != nil {
+		return nil, fmt.Errorf("invalid evidence: %v", err)
+	}
+	if ev.VoteA == nil || ev.VoteB == nil {
+		return nil, errors.New("evidence is missing a vote")
+	}
+	if ev.VoteA.ValidatorID != evidence.ValidatorID || ev.VoteA.Height != evidence.Height {
+		return nil, errors.New("evidence header does not match its votes")
+	}
+	return &ev, nil
+}
+
+// EvidencePool holds evidence waiting to be included in a block.
+type EvidencePool struct {
+	mutex    sync.Mutex
+	evidence map[string]*DuplicateVoteEvidence
+	order    []string
+}
+
+// NewEvidencePool creates an empty evidence pool.
+func NewEvidencePool() *EvidencePool {
+	return &EvidencePool{evidence: make(map[string]*DuplicateVoteEvidence)}
+}
+
+// Add stores evidence, ignoring duplicates.
+func (p *EvidencePool) Add(ev *DuplicateVoteEvidence) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	hash := ev.Hash()
+	if _, exists := p.evidence[hash]; exists {
+		return
+	}
+	p.evidence[hash] = ev
+	p.order = append(p.order, hash)
+}
+
+// Pending returns the pooled evidence in the order it was received.
+func (p *EvidencePool) Pending() []*DuplicateVoteEvidence {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	pending := make([]*DuplicateVoteEvidence, 0, len(p.order))
+	for _, hash := range p.order {
+		pending = append(pending, p.evidence[hash])
+	}
+	return pending
+}
+
+// Remove drops evidence that has been included in a block.
+func (p *EvidencePool) Remove(evidence []transaction.Evidence) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, e := range evidence {
+		ev, err := DecodeDuplicateVoteEvidence(e)
+		if err != nil {
+			continue
+		}
+		delete(p.evidence, ev.Hash())
+	}
+	order := p.order[:0]
+	for _, hash := range p.order {
+		if _, exists := p.evidence[hash]; exists {
+			order = append(order, hash)
+		}
+	}
+	p.order = order
+}
@@ -0,0 +1,66 @@
+package blockchain
+
+import (
+	"fmt"
+	"matrix-blockchain/transaction"
+	"sync"
+)
+
+// LightClient verifies that blocks are final without executing them. It
+// starts from a trusted validator set and checks each block's commit
+// certificate, following validator set changes as it goes.
+type LightClient struct {
+	mutex         sync.Mutex
+	trusted       *ValidatorSet
+	trustedHeight int64 // Height of the latest block verified as final
+}
+
+// NewLightClient creates a light client trusting the validator set at height,
+// obtained out of band, e.g. from genesis.
+func NewLightClient(trusted *ValidatorSet, height int64) *LightClient {
+	return &LightClient{trusted: trusted, trustedHeight: height}
+}
+
+// VerifyFinality checks that block is final: validators must be the set its
+// header commits to, and cert must carry their precommits with more than 2/3
+// of the voting power. When the set differs from the trusted one, validators
+// holding more than 1/3 of the trusted power must also have signed, so at
+// least one honest trusted validator vouches for the new set. On success the
+// new set becomes trusted.
+func (lc *LightClient) VerifyFinality(block *transaction.Block, cert *CommitCertificate, validators *ValidatorSet) error {
+	lc.mutex.Lock()
+	defer lc.mutex.Unlock()
+
+	if int64(block.Index) <= lc.trustedHeight {
+		return fmt.Errorf("block %d is not after trusted height %d", block.Index, lc.trustedHeight)
+	}
+	if block.ValidatorsHash != validators.Hash() {
+		return fmt.Errorf("validator set does not match the hash in block %d", block.Index)
+	}
+	if err := VerifyCommit(block, cert, validators); err != nil {
+		return err
+	}
+
+	if validators.Hash() != lc.trusted.Hash() {
+		var power int64
+		for id := range cert.Signers() {
+			if v := lc.trusted.GetByID(id); v != nil && string(v.PubKey) == string(validators.GetByID(id).PubKey) {
+				power += v.VotingPower
+			}
+		}
+		if !lc.trusted.HasOneThird(power) {
+			return fmt.Errorf("new validator set at block %d is not vouched for by the trusted set", block.Index)
+		}
+	}
+
+	lc.trusted = validators
+	lc.trustedHeight = int64(block.Index)
+	return nil
+}
+
+// FinalizedHeight returns the height of the latest block verified as final.
+func (lc *LightClient) FinalizedHeight() int64 {
+	lc.mutex.Lock()
+	defer lc.mutex.Unlock()
+	return lc.trustedHeight
+}
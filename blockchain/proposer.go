@@ -0,0 +1,106 @@
+package blockchain
+
+// priorityWindowSizeFactor bounds the spread between the highest and lowest
+// proposer priority to this multiple of the total voting power.
+const priorityWindowSizeFactor = 2
+
+// Proposer returns the validator proposing the block at the given height and
+// round. Selection is Tendermint's weighted round-robin: every step each
+// validator's priority grows by its voting power, the validator with the
+// highest priority proposes and its priority drops by the total voting power.
+// The set steps once per height since StartHeight and once per round, so every
+// node derives the same proposer and each validator proposes in proportion to
+// its voting power.
+func (vs *ValidatorSet) Proposer(height int64, round int) *ConsensusValidator {
+	if len(vs.Validators) == 0 || height < vs.StartHeight || round < 0 {
+		return nil
+	}
+
+	vs.mutex.Lock()
+	defer vs.mutex.Unlock()
+
+	// Priorities at the start of the height are cached since consensus asks
+	// for consecutive heights; rounds are applied to a copy
+	heightSteps := height - vs.StartHeight + 1
+	if !vs.proposerCached || vs.proposerSteps > heightSteps {
+		vs.proposerCache = vs.initialPriorities()
+		vs.proposerSteps = 0
+		vs.proposerCached = true
+	}
+	for ; vs.proposerSteps < heightSteps-1; vs.proposerSteps++ {
+		vs.incrementPriorities(vs.proposerCache)
+	}
+
+	priorities := append([]int64{}, vs.proposerCache...)
+	var proposer int
+	for i := 0; i <= round; i++ {
+		proposer = vs.incrementPriorities(priorities)
+	}
+	return vs.Validators[proposer]
+}
+
+// initialPriorities returns the validators' priorities as of StartHeight.
+func (vs *ValidatorSet) initialPriorities() []int64 {
+	priorities := make([]int64, len(vs.Validators))
+	for i, v := range vs.Validators {
+		priorities[i] = v.ProposerPriority
+	}
+	return priorities
+}
+
+// incrementPriorities performs one selection step and returns the index of the selected proposer.
+func (vs *ValidatorSet) incrementPriorities(priorities []int64) int {
+	vs.rescalePriorities(priorities)
+	vs.centerPriorities(priorities)
+
+	proposer := 0
+	for i, v := range vs.Validators {
+		priorities[i] += v.VotingPower
+		// Validators are ordered by ID, so ties go to the lowest ID
+		if priorities[i] > priorities[proposer] {
+			proposer = i
+		}
+	}
+	priorities[proposer] -= vs.totalVotingPower
+	return proposer
+}
+
+// rescalePriorities shrinks priorities whose spread exceeds the window so a
+// validator joining with a very low priority can't be starved indefinitely.
+func (vs *ValidatorSet) rescalePriorities(priorities []int64) {
+	window := priorityWindowSizeFactor * vs.totalVotingPower
+	if window == 0 {
+		return
+	}
+
+	lowest, highest := priorities[0], priorities[0]
+	for _, p := range priorities {
+		if p < lowest {
+			lowest = p
+		}
+		if p > highest {
+			highest = p
+		}
+	}
+
+	diff := highest - lowest
+	if diff <= window {
+		return
+	}
+	ratio := (diff + window - 1) / window
+	for i := range priorities {
+		priorities[i] /= ratio
+	}
+}
+
+// centerPriorities shifts priorities so they average to zero.
+func (vs *ValidatorSet) centerPriorities(priorities []int64) {
+	var sum int64
+	for _, p := range priorities {
+		sum += p
+	}
+	avg := sum / int64(len(priorities))
+	for i := range priorities {
+		priorities[i] -= avg
+	}
+}
@@ -0,0 +1,71 @@
+package blockchain
+
+import (
+	"fmt"
+	"testing"
+)
+
+// newTestSet creates a validator set with the given voting powers, named
+// val0, val1 and so on.
+func newTestSet(powers []int64, startHeight int64) *ValidatorSet {
+	validators := make([]*ConsensusValidator, len(powers))
+	for i, power := range powers {
+		validators[i] = &ConsensusValidator{ID: fmt.Sprintf("val%d", i), VotingPower: power}
+	}
+	return NewValidatorSet(validators, startHeight)
+}
+
+func TestProposerSelectionIsProportionalToPower(t *testing.T) {
+	powers := []int64{1, 2, 3, 4, 10}
+	set := newTestSet(powers, 1)
+
+	const heights = 20000
+	counts := make(map[string]int64)
+	for height := int64(1); height <= heights; height++ {
+		counts[set.Proposer(height, 0).ID]++
+	}
+
+	total := set.TotalVotingPower()
+	for i, power := range powers {
+		id := fmt.Sprintf("val%d", i)
+		want := heights * power / total
+		if diff := counts[id] - want; diff < -1 || diff > 1 {
+			t.Errorf("%s with power %d proposed %d of %d blocks, want %d", id, power, counts[id], heights, want)
+		}
+	}
+}
+
+func TestProposerSelectionIsDeterministic(t *testing.T) {
+	powers := []int64{7, 3, 12, 1}
+	a := newTestSet(powers, 5)
+
+	// Another node builds the same set from validators in a different order
+	reversed := make([]*ConsensusValidator, len(a.Validators))
+	for i, v := range a.Validators {
+		reversed[len(reversed)-1-i] = &ConsensusValidator{ID: v.ID, VotingPower: v.VotingPower}
+	}
+	b := NewValidatorSet(reversed, 5)
+
+	for height := int64(5); height < 500; height++ {
+		for round := 0; round < 3; round++ {
+			if pa, pb := a.Proposer(height, round), b.Proposer(height, round); pa.ID != pb.ID {
+				t.Fatalf("height %d round %d: proposers %s and %s differ", height, round, pa.ID, pb.ID)
+			}
+		}
+	}
+
+	// A node asking for heights out of order, e.g. after a restart, agrees too
+	c := newTestSet(powers, 5)
+	for _, height := range []int64{400, 17, 499, 5, 250} {
+		if pa, pc := a.Proposer(height, 1), c.Proposer(height, 1); pa.ID != pc.ID {
+			t.Errorf("height %d: proposers %s and %s differ", height, pa.ID, pc.ID)
+		}
+	}
+}
+
+func TestProposerBeforeStartHeight(t *testing.T) {
+	set := newTestSet([]int64{1, 1}, 10)
+	if proposer := set.Proposer(9, 0); proposer != nil {
+		t.Errorf("proposer %s before the set's start height", proposer.ID)
+	}
+}
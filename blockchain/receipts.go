@@ -0,0 +1,112 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"matrix-blockchain/transaction"
+
+	"github.com/boltdb/bolt"
+)
+
+// memoIndexKey builds the index key for a transaction under a memo tag. The
+// separator keeps a tag from matching longer tags that share its prefix.
+func memoIndexKey(tag string, txHash string) []byte {
+	return []byte(tag + "\x00" + txHash)
+}
+
+// saveReceipts stores the receipts of a block and indexes them by memo tag.
+func saveReceipts(tx *bolt.Tx, receipts []transaction.Receipt) error {
+	bucket := tx.Bucket([]byte(receiptsBucket))
+	index := tx.Bucket([]byte(memoIndexBucket))
+	if bucket == nil || index == nil {
+		return fmt.Errorf("receipts bucket not found")
+	}
+
+	for _, receipt := range receipts {
+		data, err := json.Marshal(receipt)
+		if err != nil {
+			return fmt.Errorf("failed to serialize receipt: %v", err)
+		}
+		if err := bucket.Put([]byte(receipt.TxHash), data); err != nil {
+			return fmt.Errorf("failed to save receipt: %v", err)
+		}
+
+		tag := transaction.MemoTag(receipt.Memo)
+		if tag == "" {
+			continue
+		}
+		if err := index.Put(memoIndexKey(tag, receipt.TxHash), nil); err != nil {
+			return fmt.Errorf("failed to index receipt: %v", err)
+		}
+	}
+	return nil
+}
+
+// GetReceipt retrieves the receipt of a transaction by its hash.
+func (db *Database) GetReceipt(txHash string) (*transaction.Receipt, error) {
+	var receipt *transaction.Receipt
+
+	err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(receiptsBucket))
+		if bucket == nil {
+			return fmt.Errorf("receipts bucket not found")
+		}
+
+		data := bucket.Get([]byte(txHash))
+		if data == nil {
+			return fmt.Errorf("receipt not found")
+		}
+
+		receipt = &transaction.Receipt{}
+		if err := json.Unmarshal(data, receipt); err != nil {
+			return fmt.Errorf("failed to deserialize receipt: %v", err)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// GetReceiptsByMemoTag retrieves the receipts of all transactions whose memo
+// carries the given tag, in transaction hash order. Keys of longer tags that
+// start with the tag and a separator are skipped.
+func (db *Database) GetReceiptsByMemoTag(tag string) ([]transaction.Receipt, error) {
+	var receipts []transaction.Receipt
+
+	err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(receiptsBucket))
+		index := tx.Bucket([]byte(memoIndexBucket))
+		if bucket == nil || index == nil {
+			return fmt.Errorf("receipts bucket not found")
+		}
+
+		prefix := memoIndexKey(tag, "")
+		cursor := index.Cursor()
+		for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+			txHash := key[len(prefix):]
+			if bytes.IndexByte(txHash, 0) >= 0 {
+				continue
+			}
+			data := bucket.Get(txHash)
+			if data == nil {
+				return fmt.Errorf("receipt for indexed transaction %s not found", txHash)
+			}
+
+			var receipt transaction.Receipt
+			if err := json.Unmarshal(data, &receipt); err != nil {
+				return fmt.Errorf("failed to deserialize receipt: %v", err)
+			}
+			receipts = append(receipts, receipt)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return receipts, nil
+}
@@ -0,0 +1,40 @@
+package blockchain
+
+import (
+	"matrix-blockchain/transaction"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestReceiptsByMemoTagMatchExactly(t *testing.T) {
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "blockchain.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// A key under a longer tag sharing the separator, as stored before
+	// memos were restricted to printable text
+	receipts := []transaction.Receipt{
+		{TxHash: "aa", Memo: "grant:1"},
+		{TxHash: "bb", Memo: "grant\x00x:1"},
+		{TxHash: "cc", Memo: "grants:1"},
+		{TxHash: "dd", Memo: "grant"},
+	}
+	err = db.db.Update(func(tx *bolt.Tx) error {
+		return saveReceipts(tx, receipts)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := db.GetReceiptsByMemoTag("grant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || found[0].TxHash != "aa" || found[1].TxHash != "dd" {
+		t.Errorf("receipts tagged grant: %+v, want aa and dd", found)
+	}
+}
@@ -0,0 +1,23 @@
+package blockchain
+
+import "time"
+
+// timeoutTicker schedules the consensus timeouts. Timers still pending when
+// consensus moves to a new height are stopped so stale timeouts don't pile
+// up. The consensus mutex serializes access.
+type timeoutTicker struct {
+	timers []*time.Timer
+}
+
+// schedule runs fire after duration.
+func (t *timeoutTicker) schedule(duration time.Duration, fire func()) {
+	t.timers = append(t.timers, time.AfterFunc(duration, fire))
+}
+
+// stop cancels every pending timeout.
+func (t *timeoutTicker) stop() {
+	for _, timer := range t.timers {
+		timer.Stop()
+	}
+	t.timers = nil
+}
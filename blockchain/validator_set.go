@@ -0,0 +1,112 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"matrix-blockchain/utils"
+	"sort"
+	"sync"
+)
+
+// ConsensusValidator is a member of the validator set voting on blocks.
+type ConsensusValidator struct {
+	ID               string // Validator's address
+	PubKey           []byte // Encoded public key that signs the validator's votes
+	VotingPower      int64  // Weight of the validator's votes
+	ProposerPriority int64  // Accumulated priority for proposer selection
+}
+
+// ValidatorSet is the set of validators voting from StartHeight on, ordered by ID.
+type ValidatorSet struct {
+	Validators       []*ConsensusValidator
+	StartHeight      int64 // First height at which the set votes, priorities are as of that height
+	totalVotingPower int64
+
+	mutex          sync.Mutex
+	proposerCache  []int64 // Priorities after proposerSteps increments
+	proposerSteps  int64
+	proposerCached bool
+}
+
+// NewValidatorSet creates a validator set taking effect at startHeight.
+// Validators without voting power are dropped.
+func NewValidatorSet(validators []*ConsensusValidator, startHeight int64) *ValidatorSet {
+	set := &ValidatorSet{StartHeight: startHeight}
+	for _, v := range validators {
+		if v.VotingPower <= 0 {
+			continue
+		}
+		validator := *v
+		set.Validators = append(set.Validators, &validator)
+		set.totalVotingPower += v.VotingPower
+	}
+
+	sort.Slice(set.Validators, func(i, j int) bool {
+		return set.Validators[i].ID < set.Validators[j].ID
+	})
+	return set
+}
+
+// Hash commits to the members of the set, their keys and voting power.
+// Proposer priorities are derived and not included.
+func (vs *ValidatorSet) Hash() string {
+	type member struct {
+		ID          string `json:"id"`
+		PubKey      []byte `json:"pub_key"`
+		VotingPower int64  `json:"voting_power"`
+	}
+	members := make([]member, len(vs.Validators))
+	for i, v := range vs.Validators {
+		members[i] = member{ID: v.ID, PubKey: v.PubKey, VotingPower: v.VotingPower}
+	}
+	data, _ := json.Marshal(members)
+	return utils.Hash(data)
+}
+
+// Size returns the number of validators in the set.
+func (vs *ValidatorSet) Size() int {
+	return len(vs.Validators)
+}
+
+// TotalVotingPower returns the sum of all validators' voting power.
+func (vs *ValidatorSet) TotalVotingPower() int64 {
+	return vs.totalVotingPower
+}
+
+// GetByID returns the validator with the given ID, or nil if it is not in the set.
+func (vs *ValidatorSet) GetByID(id string) *ConsensusValidator {
+	i := sort.Search(len(vs.Validators), func(i int) bool {
+		return vs.Validators[i].ID >= id
+	})
+	if i < len(vs.Validators) && vs.Validators[i].ID == id {
+		return vs.Validators[i]
+	}
+	return nil
+}
+
+// Quorum returns the smallest voting power that is strictly more than 2/3 of
+// the total. Rounding 2/3 of the total down and adding one keeps the quorum a
+// BFT majority for any total, e.g. 3 of 4 equal validators rather than 2.
+func (vs *ValidatorSet) Quorum() int64 {
+	return vs.totalVotingPower*2/3 + 1
+}
+
+// GetByPubKey returns the validator whose votes are signed by pubKey, or nil.
+func (vs *ValidatorSet) GetByPubKey(pubKey []byte) *ConsensusValidator {
+	for _, v := range vs.Validators {
+		if string(v.PubKey) == string(pubKey) {
+			return v
+		}
+	}
+	return nil
+}
+
+// HasTwoThirdsMajority reports whether power reaches the quorum.
+func (vs *ValidatorSet) HasTwoThirdsMajority(power int64) bool {
+	return power >= vs.Quorum()
+}
+
+// HasOneThird reports whether power is strictly more than 1/3 of the total,
+// so at least one honest validator is included.
+func (vs *ValidatorSet) HasOneThird(power int64) bool {
+	return power*3 > vs.totalVotingPower
+}
@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"matrix-blockchain/utils"
+	"testing"
+)
+
+func TestQuorumIsStrictlyMoreThanTwoThirds(t *testing.T) {
+	for _, tc := range []struct {
+		powers []int64
+		quorum int64
+	}{
+		{[]int64{1}, 1},
+		{[]int64{1, 1, 1}, 3},
+		{[]int64{1, 1, 1, 1}, 3},
+		{[]int64{5, 5}, 7},
+		{[]int64{33, 33, 34}, 67},
+		{[]int64{33, 33, 33}, 67},
+		{[]int64{1, 1, 1, 1, 1, 1}, 5},
+	} {
+		set := newTestSet(tc.powers, 1)
+		total := set.TotalVotingPower()
+		if got := set.Quorum(); got != tc.quorum {
+			t.Errorf("powers %v: quorum %d, want %d", tc.powers, got, tc.quorum)
+		}
+		if set.HasTwoThirdsMajority(tc.quorum-1) || !set.HasTwoThirdsMajority(tc.quorum) {
+			t.Errorf("powers %v: majority not reached exactly at %d", tc.powers, tc.quorum)
+		}
+		// The quorum is the smallest power strictly above 2/3 of the total
+		if 3*tc.quorum <= 2*total || 3*(tc.quorum-1) > 2*total {
+			t.Errorf("powers %v: quorum %d is not the smallest power above 2/3 of %d", tc.powers, tc.quorum, total)
+		}
+	}
+}
+
+func TestHasOneThird(t *testing.T) {
+	set := newTestSet([]int64{1, 1, 1}, 1)
+	if set.HasOneThird(1) {
+		t.Error("exactly 1/3 counted as more than 1/3")
+	}
+	if !set.HasOneThird(2) {
+		t.Error("2/3 not counted as more than 1/3")
+	}
+}
+
+func TestCommitCertificateNeedsQuorum(t *testing.T) {
+	// Four validators of equal power: three precommits commit, two don't
+	keys := make([]*ecdsa.PrivateKey, 4)
+	validators := make([]*ConsensusValidator, 4)
+	for i := range keys {
+		key, pub := utils.GenerateKeys()
+		keys[i] = key
+		validators[i] = &ConsensusValidator{ID: fmt.Sprintf("val%d", i), PubKey: utils.EncodePublicKey(pub), VotingPower: 10}
+	}
+	set := NewValidatorSet(validators, 1)
+
+	certificate := func(signers int) *CommitCertificate {
+		precommits := NewVoteSet(1, 0, Precommit, set)
+		for i := 0; i < signers; i++ {
+			vote := &Vote{Type: Precommit, Height: 1, BlockHash: "block", ValidatorID: fmt.Sprintf("val%d", i)}
+			if err := vote.Sign(keys[i]); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := precommits.AddVote(vote); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return NewCommitCertificate(precommits, "block")
+	}
+
+	if err := certificate(2).Verify(set); err == nil {
+		t.Error("commit with half the voting power verified")
+	}
+	if err := certificate(3).Verify(set); err != nil {
+		t.Errorf("commit with 3 of 4 validators rejected: %v", err)
+	}
+}
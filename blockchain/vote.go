@@ -0,0 +1,201 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"matrix-blockchain/transaction"
+	"matrix-blockchain/utils"
+	"sort"
+)
+
+// VoteType distinguishes the two voting steps of a consensus round.
+type VoteType string
+
+const (
+	Prevote   VoteType = "prevote"
+	Precommit VoteType = "precommit"
+)
+
+// Vote is a validator's signed prevote or precommit for a block.
+type Vote struct {
+	Type        VoteType               `json:"type"`
+	Height      int64                  `json:"height"`
+	Round       int                    `json:"round"`
+	BlockHash   string                 `json:"block_hash"` // Empty for a nil vote
+	ValidatorID string                 `json:"validator_id"`
+	Timestamp   int64                  `json:"timestamp"`
+	Signature   *transaction.Signature `json:"signature,omitempty"`
+}
+
+// SignBytes returns the canonical bytes covered by the vote signature.
+func (v *Vote) SignBytes() []byte {
+	unsigned := *v
+	unsigned.Signature = nil
+	data, _ := json.Marshal(unsigned)
+	return data
+}
+
+// Sign signs the vote with the validator's private key.
+func (v *Vote) Sign(privateKey *ecdsa.PrivateKey) error {
+	r, s, err := utils.SignTransaction(privateKey, v.SignBytes())
+	if err != nil {
+		return fmt.Errorf("failed to sign vote: %v", err)
+	}
+	v.Signature = &transaction.Signature{R: r, S: s}
+	return nil
+}
+
+// Verify checks the vote signature against the validator's encoded public key.
+func (v *Vote) Verify(pubKey []byte) bool {
+	if v.Signature == nil || v.Signature.R == nil || v.Signature.S == nil {
+		return false
+	}
+	publicKey, err := utils.DecodePublicKey(pubKey)
+	if err != nil {
+		return false
+	}
+	return utils.VerifySignature(publicKey, v.SignBytes(), v.Signature.R, v.Signature.S)
+}
+
+// VerifyVotes re-verifies persisted votes against the validator set they were
+// cast under, e.g. when auditing the votes of a past height.
+func VerifyVotes(votes []*Vote, validators *ValidatorSet) error {
+	for _, vote := range votes {
+		validator := validators.GetByID(vote.ValidatorID)
+		if validator == nil {
+			return fmt.Errorf("vote from unknown validator %s", vote.ValidatorID)
+		}
+		if !vote.Verify(validator.PubKey) {
+			return fmt.Errorf("invalid signature on %s from %s at %d/%d", vote.Type, vote.ValidatorID, vote.Height, vote.Round)
+		}
+	}
+	return nil
+}
+
+// Proposal is the block proposed by the round's proposer. POLRound is the
+// round in which the block last received a 2/3 prevote majority, or -1.
+type Proposal struct {
+	Height     int64                  `json:"height"`
+	Round      int                    `json:"round"`
+	POLRound   int                    `json:"pol_round"`
+	Block      *transaction.Block     `json:"block"`
+	ProposerID string                 `json:"proposer_id"`
+	Signature  *transaction.Signature `json:"signature,omitempty"`
+}
+
+// SignBytes returns the canonical bytes covered by the proposal signature.
+func (p *Proposal) SignBytes() []byte {
+	data, _ := json.Marshal(struct {
+		Height     int64  `json:"height"`
+		Round      int    `json:"round"`
+		POLRound   int    `json:"pol_round"`
+		BlockHash  string `json:"block_hash"`
+		ProposerID string `json:"proposer_id"`
+	}{p.Height, p.Round, p.POLRound, p.Block.Hash, p.ProposerID})
+	return data
+}
+
+// Sign signs the proposal with the proposer's private key.
+func (p *Proposal) Sign(privateKey *ecdsa.PrivateKey) error {
+	r, s, err := utils.SignTransaction(privateKey, p.SignBytes())
+	if err != nil {
+		return fmt.Errorf("failed to sign proposal: %v", err)
+	}
+	p.Signature = &transaction.Signature{R: r, S: s}
+	return nil
+}
+
+// Verify checks the proposal signature against the proposer's encoded public key.
+func (p *Proposal) Verify(pubKey []byte) bool {
+	if p.Block == nil || p.Signature == nil || p.Signature.R == nil || p.Signature.S == nil {
+		return false
+	}
+	publicKey, err := utils.DecodePublicKey(pubKey)
+	if err != nil {
+		return false
+	}
+	return utils.VerifySignature(publicKey, p.SignBytes(), p.Signature.R, p.Signature.S)
+}
+
+// VoteSet collects the votes of one type for a single height and round.
+type VoteSet struct {
+	Height     int64
+	Round      int
+	Type       VoteType
+	validators *ValidatorSet
+	votes      map[string]*Vote // Votes by validator ID
+	blockPower map[string]int64 // Voting power behind each block hash
+	totalPower int64            // Voting power of all votes received
+}
+
+// NewVoteSet creates an empty vote set.
+func NewVoteSet(height int64, round int, voteType VoteType, validators *ValidatorSet) *VoteSet {
+	return &VoteSet{
+		Height:     height,
+		Round:      round,
+		Type:       voteType,
+		validators: validators,
+		votes:      make(map[string]*Vote),
+		blockPower: make(map[string]int64),
+	}
+}
+
+// AddVote verifies and records a vote. It returns false without an error if
+// the vote was already known.
+func (vs *VoteSet) AddVote(vote *Vote) (bool, error) {
+	if vote.Height != vs.Height || vote.Round != vs.Round || vote.Type != vs.Type {
+		return false, fmt.Errorf("vote for %d/%d/%s added to vote set for %d/%d/%s",
+			vote.Height, vote.Round, vote.Type, vs.Height, vs.Round, vs.Type)
+	}
+
+	validator := vs.validators.GetByID(vote.ValidatorID)
+	if validator == nil {
+		return false, fmt.Errorf("vote from unknown validator %s", vote.ValidatorID)
+	}
+
+	existing, exists := vs.votes[vote.ValidatorID]
+	if exists && existing.BlockHash == vote.BlockHash {
+		return false, nil
+	}
+
+	if !vote.Verify(validator.PubKey) {
+		return false, fmt.Errorf("invalid signature on vote from %s", vote.ValidatorID)
+	}
+	if exists {
+		return false, &ConflictingVoteError{Evidence: NewDuplicateVoteEvidence(existing, vote)}
+	}
+
+	vs.votes[vote.ValidatorID] = vote
+	vs.blockPower[vote.BlockHash] += validator.VotingPower
+	vs.totalPower += validator.VotingPower
+	return true, nil
+}
+
+// TwoThirdsMajority returns the block hash that received more than 2/3 of the
+// voting power. An empty hash with ok set means a 2/3 majority for nil.
+func (vs *VoteSet) TwoThirdsMajority() (blockHash string, ok bool) {
+	for hash, power := range vs.blockPower {
+		if vs.validators.HasTwoThirdsMajority(power) {
+			return hash, true
+		}
+	}
+	return "", false
+}
+
+// HasTwoThirdsAny reports whether more than 2/3 of the voting power has voted for anything.
+func (vs *VoteSet) HasTwoThirdsAny() bool {
+	return vs.validators.HasTwoThirdsMajority(vs.totalPower)
+}
+
+// Votes returns all votes in the set ordered by validator ID.
+func (vs *VoteSet) Votes() []*Vote {
+	votes := make([]*Vote, 0, len(vs.votes))
+	for _, vote := range vs.votes {
+		votes = append(votes, vote)
+	}
+	sort.Slice(votes, func(i, j int) bool {
+		return votes[i].ValidatorID < votes[j].ValidatorID
+	})
+	return votes
+}
@@ -0,0 +1,137 @@
+package blockchain
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// WAL entry types.
+const (
+	walProposal       = "proposal"        // Proposal received from a peer
+	walVote           = "vote"            // Vote received from a peer
+	walSignedProposal = "signed-proposal" // Proposal we signed, written before it is sent
+	walSignedVote     = "signed-vote"     // Vote we signed, written before it is sent
+)
+
+// WALEntry is a single consensus message recorded in the write-ahead log.
+type WALEntry struct {
+	Type     string    `json:"type"`
+	Height   int64     `json:"height"`
+	Proposal *Proposal `json:"proposal,omitempty"`
+	Vote     *Vote     `json:"vote,omitempty"`
+}
+
+// WAL is the consensus write-ahead log. It records every consensus message
+// we receive and every proposal and vote we sign before it is sent, so a
+// restarted node can restore its round state and never signs twice.
+// The log only keeps the current height and is reset when a block commits.
+type WAL struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// OpenWAL opens the write-ahead log at path, creating it if needed.
+func OpenWAL(path string) (*WAL, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open consensus WAL: %v", err)
+	}
+	return &WAL{file: file}, nil
+}
+
+// Write appends an entry. With sync set the entry is flushed to disk before
+// Write returns, which is required for messages we sign.
+func (w *WAL) Write(entry WALEntry, sync bool) error {
+	if w == nil {
+		return nil
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode WAL entry: %v", err)
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if _, err := w.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write WAL entry: %v", err)
+	}
+	if sync {
+		return w.file.Sync()
+	}
+	return nil
+}
+
+// ReadAll returns the entries in the log. A partially written last entry,
+// left by a crash mid-write, is ignored.
+func (w *WAL) ReadAll() ([]WALEntry, error) {
+	if w == nil {
+		return nil, nil
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read consensus WAL: %v", err)
+	}
+	var entries []WALEntry
+	var corrupt error
+	scanner := bufio.NewScanner(w.file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if corrupt != nil {
+			return nil, corrupt
+		}
+		var entry WALEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			corrupt = fmt.Errorf("corrupt consensus WAL entry: %v", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read consensus WAL: %v", err)
+	}
+	return entries, nil
+}
+
+// Reset discards the log and starts it over with the given entries.
+func (w *WAL) Reset(entries []WALEntry) error {
+	if w == nil {
+		return nil
+	}
+	w.mutex.Lock()
+	if err := w.file.Truncate(0); err != nil {
+		w.mutex.Unlock()
+		return fmt.Errorf("failed to truncate: %v", err)
+	}
+	w.mutex.Unlock()
+
+	for _, entry := range entries {
+		if err := w.Write(entry, false); err != nil {
+			return err
+		}
+	}
+	return w.Sync()
+}
+
+// Sync flushes the log to disk.
+func (w *WAL) Sync() error {
+	if w == nil {
+		return nil
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.file.Sync()
+}
+
+// Close closes the log file.
+func (w *WAL) Close() error {
+	if w == nil {
+		return nil
+	}
+	return w.file.Close()
+}
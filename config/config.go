@@ -0,0 +1,73 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Config holds the node settings read from config.json.
+type Config struct {
+	Consensus ConsensusConfig `json:"consensus"`
+}
+
+// ConsensusConfig sets the consensus timeouts, in milliseconds, and block production policy.
+type ConsensusConfig struct {
+	TimeoutPropose      int64 `json:"timeout_propose_ms"`
+	TimeoutPrevote      int64 `json:"timeout_prevote_ms"`
+	TimeoutPrecommit    int64 `json:"timeout_precommit_ms"`
+	TimeoutDelta        int64 `json:"timeout_delta_ms"`         // Added to each timeout per failed round
+	BlockInterval       int64 `json:"block_interval_ms"`        // Target time between blocks
+	CreateEmptyBlocks   bool  `json:"create_empty_blocks"`      // Produce blocks without transactions
+	EmptyBlocksInterval int64 `json:"empty_blocks_interval_ms"` // Without empty blocks, produce one anyway after this long; 0 waits for transactions
+}
+
+// DefaultConfig returns the settings used for anything config.json omits.
+func DefaultConfig() Config {
+	return Config{
+		Consensus: ConsensusConfig{
+			TimeoutPropose:    3000,
+			TimeoutPrevote:    1000,
+			TimeoutPrecommit:  1000,
+			TimeoutDelta:      500,
+			BlockInterval:     5000,
+			CreateEmptyBlocks: true,
+		},
+	}
+}
+
+// LoadConfig reads the node settings from a JSON file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	config := DefaultConfig()
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate checks that the settings are usable.
+func (c *Config) Validate() error {
+	t := c.Consensus
+	if t.TimeoutPropose <= 0 || t.TimeoutPrevote <= 0 || t.TimeoutPrecommit <= 0 {
+		return errors.New("consensus timeouts must be positive")
+	}
+	if t.TimeoutDelta < 0 || t.BlockInterval < 0 || t.EmptyBlocksInterval < 0 {
+		return errors.New("consensus timeout delta and intervals cannot be negative")
+	}
+	return nil
+}
+
+// Duration converts a setting in milliseconds to a duration.
+func Duration(ms int64) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
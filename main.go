@@ -1,46 +1,60 @@
 package main
 
 import (
+	"encoding/hex"
 	"fmt"
 	"log"
+	"matrix-blockchain/api"
 	"matrix-blockchain/blockchain"
+	"matrix-blockchain/config"
 	"matrix-blockchain/network"
-	"matrix-blockchain/staking"
+	"matrix-blockchain/node"
+	"matrix-blockchain/state"
 	"matrix-blockchain/transaction"
+	"matrix-blockchain/utils"
 )
 
 func main() {
 	// Initialize the Blockchain Database
-	db, err := blockchain.OpenDatabase()
+	db, err := blockchain.OpenDatabase("blockchain.db")
 	if err != nil {
 		log.Fatalf("Failed to open blockchain database: %v", err)
 	}
 	defer db.Close()
 
-	// Load the latest block or initialize the genesis block if none exists
-	latestBlock, err := db.GetLatestBlock()
+	// Open the consensus write-ahead log
+	wal, err := blockchain.OpenWAL("consensus.wal")
 	if err != nil {
-		fmt.Println("No blocks found. Creating the genesis block...")
+		log.Fatalf("Failed to open consensus WAL: %v", err)
+	}
+	defer wal.Close()
 
-		// Create and save the genesis block
-		genesisBlock := blockchain.createGenesisBlock()
-		err = db.SaveBlock(genesisBlock)
-		if err != nil {
-			log.Fatalf("Failed to save genesis block: %v", err)
-		}
-		fmt.Println("Genesis block created successfully!")
-		latestBlock = genesisBlock
-	} else {
-		fmt.Printf("Latest block found: %s\n", latestBlock.Hash)
+	// Load the node settings, the genesis state and this node's validator key
+	cfg, err := config.LoadConfig("config.json")
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	genesis, err := state.LoadGenesis("genesis.json")
+	if err != nil {
+		log.Fatalf("Failed to load genesis: %v", err)
 	}
+	validatorKey, err := utils.LoadOrGenerateKey("validator.key")
+	if err != nil {
+		log.Fatalf("Failed to load validator key: %v", err)
+	}
+	fmt.Printf("Validator address: %s\n", utils.PublicKeyToAddress(&validatorKey.PublicKey))
+	fmt.Printf("Validator public key: %s\n", hex.EncodeToString(utils.EncodePublicKey(&validatorKey.PublicKey)))
 
-	// Initialize Validators
-	validators := &staking.Validators{}
-	validators.AddValidator("Validator1", 1000)
-	validators.AddValidator("Validator2", 2000)
+	// Restore the chain and wire consensus to the P2P network
+	p2pNetwork := network.NewP2PNetwork()
+	chainNode, err := node.NewNode(db, wal, genesis, cfg, validatorKey, p2pNetwork)
+	if err != nil {
+		log.Fatalf("Failed to start node: %v", err)
+	}
 
 	// Example: Create and verify a transaction
-	tx, err := transaction.NewTransaction("MRX-SenderAddress", "MRX-ReceiverAddress", 500, "PrivateKeyExample")
+	senderKey, senderPubKey := utils.GenerateKeys()
+	tx, err := transaction.NewTransaction(utils.PublicKeyToAddress(senderPubKey), "MRX-ReceiverAddress", 500, 0, senderKey)
 	if err != nil {
 		fmt.Println("Error creating transaction:", err)
 		return
@@ -53,40 +67,30 @@ func main() {
 		return
 	}
 
-	// Example: Add a new block
-	newBlock := blockchain.CreateNewBlock(latestBlock)
-	newBlock.AddTransaction(tx)
-	err = db.SaveBlock(newBlock)
-	if err != nil {
-		log.Fatalf("Failed to save the new block: %v", err)
+	if err := chainNode.SubmitTransaction(tx); err != nil {
+		fmt.Println("Error submitting transaction:", err)
 	}
-	fmt.Printf("New block added: %s\n", newBlock.Hash)
 
 	// Example: Apply tax on a transaction
 	transaction.TransactionWithTax("MRX-Address1", "MRX-Address2", 1000, 5)
 
-	// Initialize Consensus and Voting
-	consensus := blockchain.NewConsensus(validators.GetTopValidators(), newBlock.Hash)
-	consensus.StartVoting()
-	if consensus.IsConsensusAchieved() {
-		consensus.FinalizeBlock()
-		fmt.Println("Consensus achieved and block finalized.")
-	} else {
-		fmt.Println("Consensus not achieved. Block not finalized.")
-	}
-
-	// Distribute Rewards
-	reward := staking.DistributeValidatorReward(1000)
-	fmt.Println("Validator reward distribution:", reward)
-
 	// Start the P2P Network
-	p2pNetwork := network.NewP2PNetwork()
 	err = p2pNetwork.Start("8080")
 	if err != nil {
 		log.Fatalf("Failed to start P2P network: %v", err)
 	}
 
-	// Example: Broadcast the block
-	p2pNetwork.BroadcastBlock(newBlock)
-	fmt.Println("Block broadcasted via P2P network.")
+	// Serve chain data and finality status to clients
+	err = api.NewServer(chainNode).Start("8081")
+	if err != nil {
+		log.Fatalf("Failed to start API server: %v", err)
+	}
+
+	// Take part in consensus until the process is stopped. A consensus WAL
+	// that can't be replayed must be dealt with by the operator first.
+	if err := chainNode.Start(); err != nil {
+		log.Fatalf("Failed to start consensus: %v", err)
+	}
+	fmt.Println("Consensus started.")
+	select {}
 }
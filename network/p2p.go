@@ -1,15 +1,36 @@
 package network
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
 	"net"
 	"sync"
 )
 
+// Message types exchanged between peers.
+const (
+	MsgBlock        = "block"
+	MsgBlockRequest = "block_request"
+	MsgTransaction  = "transaction"
+	MsgProposal     = "proposal"
+	MsgVote         = "vote"
+)
+
+// Message is a single newline-delimited JSON message sent between peers.
+type Message struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// MessageHandler is called for every message received from a peer.
+type MessageHandler func(peer string, msg Message)
+
 // Peer represents a single peer in the network.
 type Peer struct {
 	Address string // Peer address (IP:Port)
 	Conn    net.Conn
+	mutex   sync.Mutex // Serializes writes to Conn
 }
 
 // P2PNetwork manages the peer-to-peer network.
@@ -17,6 +38,7 @@ type P2PNetwork struct {
 	Peers    map[string]*Peer // Connected peers
 	mutex    sync.Mutex       // Mutex to handle concurrent access
 	listener net.Listener     // Network listener
+	handler  MessageHandler   // Receives decoded messages from all peers
 }
 
 // NewP2PNetwork initializes a new P2P network.
@@ -26,6 +48,13 @@ func NewP2PNetwork() *P2PNetwork {
 	}
 }
 
+// SetMessageHandler registers the function that receives peer messages.
+func (network *P2PNetwork) SetMessageHandler(handler MessageHandler) {
+	network.mutex.Lock()
+	defer network.mutex.Unlock()
+	network.handler = handler
+}
+
 // Start starts the P2P network on a given port.
 func (network *P2PNetwork) Start(port string) error {
 	listener, err := net.Listen("tcp", ":"+port)
@@ -61,30 +90,44 @@ func (network *P2PNetwork) handleConnection(conn net.Conn) {
 		Conn:    conn,
 	}
 
+	network.addPeer(peer)
+	go network.readMessages(peer)
+}
+
+// addPeer registers a connected peer.
+func (network *P2PNetwork) addPeer(peer *Peer) {
 	network.mutex.Lock()
 	network.Peers[peer.Address] = peer
 	network.mutex.Unlock()
 
 	fmt.Printf("Connected to peer: %s\n", peer.Address)
+}
 
-	// Example: Reading messages from peer (basic handshake or communication)
-	go func() {
-		defer conn.Close()
-		buf := make([]byte, 1024)
-		for {
-			n, err := conn.Read(buf)
-			if err != nil {
-				fmt.Printf("Connection with %s closed\n", peer.Address)
-				network.mutex.Lock()
-				delete(network.Peers, peer.Address)
-				network.mutex.Unlock()
-				break
-			}
-
-			message := string(buf[:n])
-			fmt.Printf("Received from %s: %s\n", peer.Address, message)
+// readMessages decodes messages from a peer until the connection closes.
+func (network *P2PNetwork) readMessages(peer *Peer) {
+	defer peer.Conn.Close()
+
+	scanner := bufio.NewScanner(peer.Conn)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			fmt.Printf("Invalid message from %s: %v\n", peer.Address, err)
+			continue
+		}
+
+		network.mutex.Lock()
+		handler := network.handler
+		network.mutex.Unlock()
+		if handler != nil {
+			handler(peer.Address, msg)
 		}
-	}()
+	}
+
+	fmt.Printf("Connection with %s closed\n", peer.Address)
+	network.mutex.Lock()
+	delete(network.Peers, peer.Address)
+	network.mutex.Unlock()
 }
 
 // ConnectToPeer connects to a new peer by address.
@@ -99,10 +142,67 @@ func (network *P2PNetwork) ConnectToPeer(address string) error {
 		Conn:    conn,
 	}
 
+	network.addPeer(peer)
+	go network.readMessages(peer)
+	return nil
+}
+
+// Send writes a message to a single peer.
+func (peer *Peer) Send(msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %v", err)
+	}
+
+	peer.mutex.Lock()
+	defer peer.mutex.Unlock()
+	_, err = peer.Conn.Write(append(data, '\n'))
+	return err
+}
+
+// SendPayload encodes a payload and sends it to a single peer as a message of the given type.
+func (network *P2PNetwork) SendPayload(address string, msgType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", msgType, err)
+	}
+
+	network.mutex.Lock()
+	peer, exists := network.Peers[address]
+	network.mutex.Unlock()
+	if !exists {
+		return fmt.Errorf("peer %s is not connected", address)
+	}
+	return peer.Send(Message{Type: msgType, Payload: data})
+}
+
+// Broadcast sends a message to every connected peer.
+func (network *P2PNetwork) Broadcast(msg Message) error {
 	network.mutex.Lock()
-	network.Peers[address] = peer
+	peers := make([]*Peer, 0, len(network.Peers))
+	for _, peer := range network.Peers {
+		peers = append(peers, peer)
+	}
 	network.mutex.Unlock()
 
-	fmt.Printf("Connected to peer: %s\n", address)
+	for _, peer := range peers {
+		if err := peer.Send(msg); err != nil {
+			fmt.Printf("Failed to send %s to %s: %v\n", msg.Type, peer.Address, err)
+		}
+	}
 	return nil
 }
+
+// BroadcastPayload encodes a payload and broadcasts it as a message of the given type.
+func (network *P2PNetwork) BroadcastPayload(msgType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", msgType, err)
+	}
+	return network.Broadcast(Message{Type: msgType, Payload: data})
+}
+
+// BroadcastBlock sends a block to every connected peer.
+func (network *P2PNetwork) BroadcastBlock(block interface{}) error {
+	return network.BroadcastPayload(MsgBlock, block)
+}
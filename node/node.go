@@ -0,0 +1,724 @@
+package node
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"matrix-blockchain/blockchain"
+	"matrix-blockchain/config"
+	"matrix-blockchain/network"
+	"matrix-blockchain/staking"
+	"matrix-blockchain/state"
+	"matrix-blockchain/transaction"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxBlockTxs = 1000
+	DefaultMempoolSize = 10000
+)
+
+// Node ties the block store, chain state, mempool, consensus and P2P network together.
+type Node struct {
+	mutex       sync.Mutex
+	db          *blockchain.Database
+	genesis     *state.Genesis
+	state       *state.State
+	latestBlock *transaction.Block
+	sigCache    *transaction.SignatureCache
+	mempool     *transaction.Mempool
+	evidence    *blockchain.EvidencePool
+	network     *network.P2PNetwork
+	consensus   *blockchain.Consensus
+	validators  *blockchain.ValidatorSet // Validators voting on the next block
+	lastSet     *blockchain.ValidatorSet // Validators that voted on the latest block
+	lastCommit  []byte                   // Encoded commit certificate of the latest block
+	audit       supplyAudit              // Incremental replay behind AuditSupply
+	sync        blockSync                // Blocks requested from peers while catching up
+	MaxBlockTxs int                      // Maximum number of transactions per proposed block
+}
+
+// supplyAudit is the chain replayed by AuditSupply, kept so each audit only
+// replays the blocks committed since the previous one.
+type supplyAudit struct {
+	mutex  sync.Mutex
+	state  *state.State // Replayed state, nil until the first audit
+	height int          // Height the state was replayed to
+	err    error        // Discrepancy found, stored blocks never change
+}
+
+// NewNode restores the chain state by replaying the stored blocks on top of
+// genesis, creating the genesis block if the store is empty. wal records the
+// consensus messages of the current height across restarts.
+func NewNode(db *blockchain.Database, wal *blockchain.WAL, genesis *state.Genesis, cfg *config.Config, privateKey *ecdsa.PrivateKey, p2p *network.P2PNetwork) (*Node, error) {
+	sigCache := transaction.NewSignatureCache(0)
+	n := &Node{
+		db:          db,
+		genesis:     genesis,
+		sigCache:    sigCache,
+		mempool:     transaction.NewMempool(DefaultMempoolSize, sigCache),
+		evidence:    blockchain.NewEvidencePool(),
+		network:     p2p,
+		MaxBlockTxs: DefaultMaxBlockTxs,
+	}
+	chainState, err := n.genesisState()
+	if err != nil {
+		return nil, err
+	}
+	if len(chainState.ActiveSet) == 0 {
+		return nil, errors.New("genesis has no validators, no block could ever be committed")
+	}
+	n.state = chainState
+
+	n.validators = validatorSetFromState(n.state, 1)
+	if err := db.SaveValidatorSet(n.validators); err != nil {
+		return nil, err
+	}
+	if err := n.loadChain(); err != nil {
+		return nil, err
+	}
+
+	n.consensus = blockchain.NewConsensus(n, p2p, db, wal, privateKey)
+	n.consensus.TimeoutPropose = config.Duration(cfg.Consensus.TimeoutPropose)
+	n.consensus.TimeoutPrevote = config.Duration(cfg.Consensus.TimeoutPrevote)
+	n.consensus.TimeoutPrecommit = config.Duration(cfg.Consensus.TimeoutPrecommit)
+	n.consensus.TimeoutDelta = config.Duration(cfg.Consensus.TimeoutDelta)
+	n.consensus.BlockInterval = config.Duration(cfg.Consensus.BlockInterval)
+	n.consensus.CreateEmptyBlocks = cfg.Consensus.CreateEmptyBlocks
+	n.consensus.EmptyBlocksInterval = config.Duration(cfg.Consensus.EmptyBlocksInterval)
+	p2p.SetMessageHandler(n.handleMessage)
+	return n, nil
+}
+
+// genesisState creates the chain state at height 0.
+func (n *Node) genesisState() (*state.State, error) {
+	return state.NewStateFromGenesis(n.genesis, staking.NewStakingSystem())
+}
+
+// loadChain restores the chain state from the latest snapshot in the state
+// store, or from genesis, and replays the stored blocks after it.
+func (n *Node) loadChain() error {
+	latest, err := n.db.GetLatestBlock()
+	if err != nil {
+		fmt.Println("No blocks found. Creating the genesis block...")
+		genesisBlock := transaction.NewGenesisBlock(n.genesis.GenesisTime)
+		if err := n.db.SaveBlock(&genesisBlock); err != nil {
+			return fmt.Errorf("failed to save genesis block: %v", err)
+		}
+		n.latestBlock = &genesisBlock
+		return nil
+	}
+
+	start, err := n.restoreState(latest.Index)
+	if err != nil {
+		return err
+	}
+	for height := start + 1; height <= latest.Index; height++ {
+		block, err := n.db.GetBlockByHeight(height)
+		if err != nil {
+			return err
+		}
+		if _, err := n.state.ApplyBlock(block); err != nil {
+			return fmt.Errorf("failed to replay block %d: %v", height, err)
+		}
+		supply, err := encodeSupply(n.state)
+		if err != nil {
+			return err
+		}
+		if err := n.db.SaveSupply(n.state.Height, supply); err != nil {
+			return err
+		}
+		validators := n.electedValidators(n.state, block)
+		if validators != nil {
+			if err := n.db.SaveValidatorSet(validators); err != nil {
+				return err
+			}
+		}
+		n.advance(n.state, block, validators)
+	}
+
+	if latest.Index > 0 {
+		commit, err := n.db.GetCommit(latest.Index)
+		if err != nil {
+			return err
+		}
+		if n.lastCommit, err = commit.Encode(); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Replayed %d blocks, latest block: %s\n", latest.Index-start, latest.Hash)
+	return nil
+}
+
+// restoreState loads the latest state snapshot taken at or below maxHeight
+// together with the validator sets in effect, and returns its height. Without
+// a usable snapshot the genesis state is kept and 0 is returned.
+func (n *Node) restoreState(maxHeight int) (int, error) {
+	genesisBlock, err := n.db.GetBlockByHeight(0)
+	if err != nil {
+		return 0, err
+	}
+	n.latestBlock = genesisBlock
+
+	height, data, err := n.db.GetState()
+	if err != nil || height <= 0 || height > int64(maxHeight) {
+		return 0, nil
+	}
+	restored, err := state.DecodeState(data)
+	if err != nil {
+		return 0, err
+	}
+	block, err := n.db.GetBlockByHeight(int(height))
+	if err != nil {
+		return 0, err
+	}
+	if n.validators, err = n.db.GetValidatorSet(height + 1); err != nil {
+		return 0, err
+	}
+	if n.lastSet, err = n.db.GetValidatorSet(height); err != nil {
+		return 0, err
+	}
+
+	n.state = restored
+	n.latestBlock = block
+	fmt.Printf("Restored chain state at height %d\n", height)
+	return int(height), nil
+}
+
+// Start begins taking part in consensus at the height after the latest block.
+func (n *Node) Start() error {
+	n.mutex.Lock()
+	height := int64(n.latestBlock.Index) + 1
+	n.mutex.Unlock()
+
+	return n.consensus.Start(height)
+}
+
+// SubmitTransaction adds a transaction to the mempool and relays it to peers.
+func (n *Node) SubmitTransaction(tx *transaction.Transaction) error {
+	if err := n.mempool.Add(tx, n.committedState()); err != nil {
+		return err
+	}
+	n.consensus.TxsAvailable()
+	return n.network.BroadcastPayload(network.MsgTransaction, tx)
+}
+
+// handleMessage dispatches a message received from a peer.
+func (n *Node) handleMessage(peer string, msg network.Message) {
+	var err error
+	switch msg.Type {
+	case network.MsgProposal, network.MsgVote:
+		n.checkBehind(peer, msg)
+		err = n.consensus.HandleMessage(msg)
+	case network.MsgBlockRequest:
+		var request blockRequest
+		if err = json.Unmarshal(msg.Payload, &request); err == nil {
+			err = n.serveBlocks(peer, &request)
+		}
+	case network.MsgBlock:
+		var committed committedBlock
+		if err = json.Unmarshal(msg.Payload, &committed); err == nil {
+			err = n.addCommittedBlock(peer, &committed)
+		}
+	case network.MsgTransaction:
+		var tx transaction.Transaction
+		if err = json.Unmarshal(msg.Payload, &tx); err == nil {
+			if err = n.mempool.Add(&tx, n.committedState()); err == nil {
+				n.consensus.TxsAvailable()
+			}
+		}
+	default:
+		return
+	}
+
+	if err != nil {
+		fmt.Printf("Rejected %s from %s: %v\n", msg.Type, peer, err)
+	}
+}
+
+// committedState returns the state of the latest block. A committed state is
+// never modified, the next block is applied to a copy.
+func (n *Node) committedState() *state.State {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.state
+}
+
+// ProposeBlock builds a block from the pending evidence and the mempool,
+// skipping evidence and transactions that would fail. They are checked in the
+// order ApplyBlock applies them, after the block's last commit has jailed and
+// slashed validators that went offline.
+// Transactions that fail for any reason other than waiting on an earlier nonce
+// are dropped from the mempool.
+func (n *Node) ProposeBlock(height int64, proposer string) (*transaction.Block, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if height != int64(n.latestBlock.Index)+1 {
+		return nil, fmt.Errorf("cannot propose height %d on top of block %d", height, n.latestBlock.Index)
+	}
+
+	now := time.Now().Unix()
+	if now <= n.latestBlock.Timestamp {
+		now = n.latestBlock.Timestamp + 1
+	}
+	scratch := n.state.Copy()
+	if err := scratch.BeginBlock(height, n.lastCommit); err != nil {
+		return nil, err
+	}
+
+	var evidence, stale []transaction.Evidence
+	for _, ev := range n.evidence.Pending() {
+		e, err := ev.ToEvidence()
+		if err != nil {
+			continue
+		}
+		if err := scratch.ApplyEvidence(&e); err != nil {
+			stale = append(stale, e)
+			continue
+		}
+		if len(evidence) < transaction.MaxBlockEvidence {
+			evidence = append(evidence, e)
+		}
+	}
+	n.evidence.Remove(stale)
+
+	var txs, failed []transaction.Transaction
+	for _, tx := range n.mempool.Pending(n.MaxBlockTxs, height, now) {
+		if err := scratch.ApplyTransaction(&tx); err != nil {
+			if tx.Nonce <= scratch.Nonce(tx.From) {
+				failed = append(failed, tx)
+			}
+			continue
+		}
+		txs = append(txs, tx)
+	}
+	n.mempool.Remove(failed)
+
+	block := transaction.NewBlock(*n.latestBlock, txs, evidence, n.lastCommit, n.validators.Hash(), proposer, now)
+	return &block, nil
+}
+
+// ValidateBlock checks a proposed block against the latest block, the commit
+// certificate it carries for the latest block, and the chain state.
+func (n *Node) ValidateBlock(block *transaction.Block) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if err := transaction.ValidateBlock(*block, *n.latestBlock, n.sigCache); err != nil {
+		return err
+	}
+	if block.ValidatorsHash != n.validators.Hash() {
+		return fmt.Errorf("block %d has validator set hash %s, expected %s", block.Index, block.ValidatorsHash, n.validators.Hash())
+	}
+	if err := n.verifyLastCommit(block); err != nil {
+		return err
+	}
+	_, err := n.state.Copy().ApplyBlock(block)
+	return err
+}
+
+// verifyLastCommit checks the commit certificate a block carries for its
+// parent. Only the block after genesis, which has no certificate, may omit it.
+func (n *Node) verifyLastCommit(block *transaction.Block) error {
+	if n.latestBlock.Index == 0 {
+		if len(block.LastCommit) != 0 {
+			return fmt.Errorf("block %d cannot carry a commit for the genesis block", block.Index)
+		}
+		return nil
+	}
+	if len(block.LastCommit) == 0 {
+		return fmt.Errorf("block %d is missing the commit for block %d", block.Index, n.latestBlock.Index)
+	}
+	cert, err := blockchain.DecodeCommitCertificate(block.LastCommit)
+	if err != nil {
+		return err
+	}
+	return blockchain.VerifyCommit(n.latestBlock, cert, n.lastSet)
+}
+
+// CommitBlock applies a decided block to the chain state and stores it with its commit certificate.
+func (n *Node) CommitBlock(block *transaction.Block, commit *blockchain.CommitCertificate) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if err := blockchain.VerifyCommit(block, commit, n.validators); err != nil {
+		return err
+	}
+	lastCommit, err := commit.Encode()
+	if err != nil {
+		return err
+	}
+
+	// The block is applied to a copy and everything is stored in one database
+	// transaction before the node moves on, so a commit that fails part way
+	// can be retried and a crash leaves either all of the height or none.
+	next := n.state.Copy()
+	receipts, err := next.ApplyBlock(block)
+	if err != nil {
+		return err
+	}
+	supply, err := encodeSupply(next)
+	if err != nil {
+		return err
+	}
+	snapshot, err := next.Encode()
+	if err != nil {
+		return err
+	}
+	validators := n.electedValidators(next, block)
+	err = n.db.SaveCommittedBlock(&blockchain.CommittedBlock{
+		Block:      block,
+		Commit:     commit,
+		Receipts:   receipts,
+		Supply:     supply,
+		State:      snapshot,
+		Validators: validators,
+	})
+	if err != nil {
+		return err
+	}
+
+	n.advance(next, block, validators)
+	n.lastCommit = lastCommit
+	n.evidence.Remove(block.Evidence)
+	n.mempool.Remove(block.Transactions)
+	n.mempool.Update(int64(block.Index), block.Timestamp, n.state)
+	return nil
+}
+
+// encodeSupply encodes the supply change of the last block applied to a state.
+func encodeSupply(s *state.State) ([]byte, error) {
+	data, err := json.Marshal(s.BlockSupply)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode supply: %v", err)
+	}
+	return data, nil
+}
+
+// electedValidators returns the validator set a state elected to vote after
+// block, or nil if it is the current set. The set is only replaced when it
+// changes so proposer rotation carries on.
+func (n *Node) electedValidators(s *state.State, block *transaction.Block) *blockchain.ValidatorSet {
+	next := validatorSetFromState(s, int64(block.Index)+1)
+	if sameValidators(next, n.validators) {
+		return nil
+	}
+	return next
+}
+
+// advance makes a block and the state it was applied to the latest, and
+// rotates the validator sets. validators is the set the block elected, nil
+// if the current set carries on; it must already be stored under the height
+// it takes effect.
+func (n *Node) advance(s *state.State, block *transaction.Block, validators *blockchain.ValidatorSet) {
+	n.state = s
+	n.latestBlock = block
+	n.lastSet = n.validators
+	if validators == nil {
+		return
+	}
+	if s.IsEpochBoundary(int64(block.Index)) {
+		fmt.Printf("Epoch %d starts with %d validators\n", s.Epoch(validators.StartHeight), validators.Size())
+	}
+	n.validators = validators
+}
+
+// VerifyCommit checks the stored commit certificate of the block at a height.
+func (n *Node) VerifyCommit(height int) error {
+	block, err := n.db.GetBlockByHeight(height)
+	if err != nil {
+		return err
+	}
+	commit, err := n.db.GetCommit(height)
+	if err != nil {
+		return err
+	}
+	validators, err := n.validatorSetAt(int64(height))
+	if err != nil {
+		return err
+	}
+	return blockchain.VerifyCommit(block, commit, validators)
+}
+
+// ValidatorSet returns the validators voting at the given height.
+func (n *Node) ValidatorSet(height int64) *blockchain.ValidatorSet {
+	set, err := n.validatorSetAt(height)
+	if err != nil {
+		fmt.Printf("Failed to load validator set for height %d: %v\n", height, err)
+		return nil
+	}
+	return set
+}
+
+// validatorSetAt returns the validators voting at a height, reading the sets
+// of past heights from the block store.
+func (n *Node) validatorSetAt(height int64) (*blockchain.ValidatorSet, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if height > int64(n.latestBlock.Index) {
+		return n.validators, nil
+	}
+	if height == int64(n.latestBlock.Index) && n.lastSet != nil {
+		return n.lastSet, nil
+	}
+	return n.db.GetValidatorSet(height)
+}
+
+// FinalityStatus reports whether a block is final and the commit certificate proving it.
+type FinalityStatus struct {
+	Height          int64                         `json:"height"`
+	Hash            string                        `json:"hash"`
+	ValidatorsHash  string                        `json:"validators_hash"`
+	Finalized       bool                          `json:"finalized"`
+	FinalizedHeight int64                         `json:"finalized_height"`
+	Commit          *blockchain.CommitCertificate `json:"commit,omitempty"`
+}
+
+// FinalizedHeight returns the height of the latest finalized block.
+func (n *Node) FinalizedHeight() (int64, error) {
+	return n.db.GetFinalizedHeight()
+}
+
+// FinalityStatus returns the finality of the block at a height. Blocks are
+// final once committed with a commit certificate; the genesis block is final
+// by definition.
+func (n *Node) FinalityStatus(height int64) (*FinalityStatus, error) {
+	block, err := n.db.GetBlockByHeight(int(height))
+	if err != nil {
+		return nil, err
+	}
+	finalized, err := n.db.GetFinalizedHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &FinalityStatus{
+		Height:          height,
+		Hash:            block.Hash,
+		ValidatorsHash:  block.ValidatorsHash,
+		Finalized:       height <= finalized,
+		FinalizedHeight: finalized,
+	}
+	if height > 0 && status.Finalized {
+		if status.Commit, err = n.db.GetCommit(int(height)); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// HasPendingTxs reports whether the mempool holds transactions for the next block.
+func (n *Node) HasPendingTxs() bool {
+	return n.mempool.Size() > 0
+}
+
+// ReportEvidence queues proof of a double-signing validator for inclusion in a block.
+func (n *Node) ReportEvidence(evidence *blockchain.DuplicateVoteEvidence) {
+	fmt.Printf("Validator %s signed conflicting votes at height %d\n", evidence.VoteA.ValidatorID, evidence.VoteA.Height)
+	n.evidence.Add(evidence)
+}
+
+// Receipt returns the receipt of a committed transaction.
+func (n *Node) Receipt(txHash string) (*transaction.Receipt, error) {
+	return n.db.GetReceipt(txHash)
+}
+
+// ReceiptsByMemoTag returns the receipts of committed transactions whose memo
+// carries a tag.
+func (n *Node) ReceiptsByMemoTag(tag string) ([]transaction.Receipt, error) {
+	return n.db.GetReceiptsByMemoTag(tag)
+}
+
+// PendingUnbondings returns a delegator's stake that is still unbonding.
+func (n *Node) PendingUnbondings(delegator string) []staking.UnbondingEntry {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.state.Staking.PendingUnbondings(delegator)
+}
+
+// PendingRedelegations returns a delegator's redelegations that have not matured yet.
+func (n *Node) PendingRedelegations(delegator string) []staking.RedelegationEntry {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.state.Staking.PendingRedelegations(delegator)
+}
+
+// PendingRewards returns the staking rewards and commission an address can withdraw.
+func (n *Node) PendingRewards(delegator string) int64 {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.state.Staking.PendingRewards(delegator)
+}
+
+// TreasuryStatus is the research treasury's unspent balance and the grant
+// funds it has reserved but not yet paid.
+type TreasuryStatus struct {
+	Balance  int64 `json:"balance"`
+	Reserved int64 `json:"reserved"`
+}
+
+// Treasury returns the research treasury's balance.
+func (n *Node) Treasury() TreasuryStatus {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	status := TreasuryStatus{Balance: n.state.Treasury}
+	for _, grant := range n.state.Grants {
+		status.Reserved += grant.Reserved()
+	}
+	return status
+}
+
+// Grants returns every grant awarded from the treasury, oldest first.
+func (n *Node) Grants() []state.Grant {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	grants := make([]state.Grant, 0, len(n.state.Grants))
+	for _, grant := range n.state.Grants {
+		g := *grant
+		g.Milestones = append([]state.GrantMilestone(nil), grant.Milestones...)
+		grants = append(grants, g)
+	}
+	sort.Slice(grants, func(i, j int) bool { return grants[i].ProposalID < grants[j].ProposalID })
+	return grants
+}
+
+// Proposal returns a governance proposal by ID.
+func (n *Node) Proposal(id uint64) (*state.Proposal, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	proposal, exists := n.state.Proposals[id]
+	if !exists {
+		return nil, fmt.Errorf("proposal %d does not exist", id)
+	}
+	p := *proposal
+	return &p, nil
+}
+
+// Supply returns the current supply report.
+func (n *Node) Supply() state.SupplyReport {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.state.SupplyReport()
+}
+
+// BlockSupply returns the recorded supply change of the block at a height.
+func (n *Node) BlockSupply(height int64) (*state.BlockSupply, error) {
+	data, err := n.db.GetSupply(height)
+	if err != nil {
+		return nil, err
+	}
+	var record state.BlockSupply
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode supply: %v", err)
+	}
+	return &record, nil
+}
+
+// AuditSupply replays the stored chain from genesis, checking that every
+// block's supply change matches its record and that all tokens stay
+// accounted for, and that the result matches the current supply. It returns
+// the supply report of the replayed chain. The replay carries on from the
+// previous audit, so every block is only replayed once.
+func (n *Node) AuditSupply() (*state.SupplyReport, error) {
+	n.audit.mutex.Lock()
+	defer n.audit.mutex.Unlock()
+
+	n.mutex.Lock()
+	latest := n.latestBlock.Index
+	current := n.state.SupplyReport()
+	n.mutex.Unlock()
+
+	if n.audit.err != nil {
+		return nil, n.audit.err
+	}
+	if n.audit.state == nil {
+		replayed, err := n.genesisState()
+		if err != nil {
+			return nil, err
+		}
+		if err := replayed.CheckSupply(); err != nil {
+			n.audit.err = fmt.Errorf("genesis: %v", err)
+			return nil, n.audit.err
+		}
+		n.audit.state = replayed
+	}
+
+	replayed := n.audit.state
+	for height := n.audit.height + 1; height <= latest; height++ {
+		block, err := n.db.GetBlockByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		record, err := n.BlockSupply(int64(height))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := replayed.ApplyBlock(block); err != nil {
+			n.audit.err = fmt.Errorf("failed to replay block %d: %v", height, err)
+			return nil, n.audit.err
+		}
+		n.audit.height = height
+		if err := replayed.CheckSupply(); err != nil {
+			n.audit.err = fmt.Errorf("block %d: %v", height, err)
+			return nil, n.audit.err
+		}
+		if *record != replayed.BlockSupply {
+			n.audit.err = fmt.Errorf("block %d: recorded supply change %+v, replay gives %+v", height, *record, replayed.BlockSupply)
+			return nil, n.audit.err
+		}
+	}
+
+	report := replayed.SupplyReport()
+	if report != current {
+		return nil, fmt.Errorf("current supply %+v, replay gives %+v", current, report)
+	}
+	return &report, nil
+}
+
+// AuditVotes replays the stored votes of a height and re-verifies their signatures.
+func (n *Node) AuditVotes(height int) ([]*blockchain.Vote, error) {
+	votes, err := n.db.GetVotes(height)
+	if err != nil {
+		return nil, err
+	}
+	validators, err := n.validatorSetAt(int64(height))
+	if err != nil {
+		return nil, err
+	}
+	return votes, blockchain.VerifyVotes(votes, validators)
+}
+
+// validatorSetFromState builds the validator set from the state's active validators.
+func validatorSetFromState(chainState *state.State, startHeight int64) *blockchain.ValidatorSet {
+	var validators []*blockchain.ConsensusValidator
+	for _, v := range chainState.ActiveSet {
+		validators = append(validators, &blockchain.ConsensusValidator{
+			ID:          v.ID,
+			PubKey:      v.PubKey,
+			VotingPower: v.Power,
+		})
+	}
+	return blockchain.NewValidatorSet(validators, startHeight)
+}
+
+// sameValidators reports whether two sets have the same members, keys and voting power.
+func sameValidators(a, b *blockchain.ValidatorSet) bool {
+	if a.Size() != b.Size() {
+		return false
+	}
+	for i := range a.Validators {
+		x, y := a.Validators[i], b.Validators[i]
+		if x.ID != y.ID || x.VotingPower != y.VotingPower || string(x.PubKey) != string(y.PubKey) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,264 @@
+package node
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"matrix-blockchain/blockchain"
+	"matrix-blockchain/config"
+	"matrix-blockchain/network"
+	"matrix-blockchain/staking"
+	"matrix-blockchain/state"
+	"matrix-blockchain/transaction"
+	"matrix-blockchain/utils"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testChain is a node whose blocks are committed by validators the test
+// holds the keys of.
+type testChain struct {
+	t       *testing.T
+	dir     string
+	genesis *state.Genesis
+	keys    map[string]*ecdsa.PrivateKey // Consensus keys by validator address
+	signers []string                     // Validators that sign each commit
+	db      *blockchain.Database
+	node    *Node
+}
+
+// newTestGenesis creates a genesis with validators bonded with the given
+// powers, whose addresses are returned in the same order.
+func newTestGenesis(powers ...int64) (*state.Genesis, []string, map[string]*ecdsa.PrivateKey) {
+	genesis := &state.Genesis{
+		GenesisTime: time.Now().Unix() - 1000,
+		Params:      staking.DefaultParams(),
+		Emission:    state.DefaultEmissionSchedule(),
+	}
+	keys := make(map[string]*ecdsa.PrivateKey)
+	var addresses []string
+	for _, power := range powers {
+		key, pub := utils.GenerateKeys()
+		address := utils.PublicKeyToAddress(pub)
+		keys[address] = key
+		addresses = append(addresses, address)
+		genesis.Validators = append(genesis.Validators, state.GenesisValidator{
+			Address:     address,
+			PubKey:      hex.EncodeToString(utils.EncodePublicKey(pub)),
+			Power:       power,
+			Description: staking.Description{Moniker: "validator"},
+		})
+	}
+	return genesis, addresses, keys
+}
+
+// newTestChain starts a node on genesis in a temporary directory.
+func newTestChain(t *testing.T, genesis *state.Genesis, keys map[string]*ecdsa.PrivateKey, signers ...string) *testChain {
+	t.Helper()
+	c := &testChain{t: t, dir: t.TempDir(), genesis: genesis, keys: keys, signers: signers}
+	c.open()
+	t.Cleanup(func() { c.db.Close() })
+	return c
+}
+
+// open starts the node from the chain stored in the test's directory.
+func (c *testChain) open() {
+	c.t.Helper()
+	var err error
+	if c.db, err = blockchain.OpenDatabase(filepath.Join(c.dir, "blockchain.db")); err != nil {
+		c.t.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	if c.node, err = NewNode(c.db, nil, c.genesis, &cfg, nil, network.NewP2PNetwork()); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// restart closes the database and starts a new node from it.
+func (c *testChain) restart() {
+	c.t.Helper()
+	c.db.Close()
+	c.open()
+}
+
+// certificate returns a commit certificate for a block signed by the chain's signers.
+func (c *testChain) certificate(block *transaction.Block) *blockchain.CommitCertificate {
+	c.t.Helper()
+	precommits := blockchain.NewVoteSet(int64(block.Index), 0, blockchain.Precommit, c.node.validators)
+	for _, id := range c.signers {
+		vote := &blockchain.Vote{Type: blockchain.Precommit, Height: int64(block.Index), BlockHash: block.Hash, ValidatorID: id}
+		if err := vote.Sign(c.keys[id]); err != nil {
+			c.t.Fatal(err)
+		}
+		if _, err := precommits.AddVote(vote); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	return blockchain.NewCommitCertificate(precommits, block.Hash)
+}
+
+// produce proposes the next block, validates it and commits it.
+func (c *testChain) produce() *transaction.Block {
+	c.t.Helper()
+	height := int64(c.node.latestBlock.Index) + 1
+	block, err := c.node.ProposeBlock(height, c.signers[0])
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := c.node.ValidateBlock(block); err != nil {
+		c.t.Fatalf("proposed block %d is invalid: %v", height, err)
+	}
+	if err := c.node.CommitBlock(block, c.certificate(block)); err != nil {
+		c.t.Fatalf("failed to commit block %d: %v", height, err)
+	}
+	return block
+}
+
+// submit signs a transaction with a payload and adds it to the mempool.
+func (c *testChain) submit(txType transaction.TxType, key *ecdsa.PrivateKey, payload transaction.Payload, nonce uint64) {
+	c.t.Helper()
+	tx, err := transaction.NewTypedTransaction(txType, utils.PublicKeyToAddress(&key.PublicKey), payload, nonce, key)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := c.node.SubmitTransaction(tx); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func TestProposalAccountsForDowntimeSlashing(t *testing.T) {
+	// The second validator never signs and is slashed at height 3, when its
+	// second missed block fills the window
+	genesis, validators, keys := newTestGenesis(100000, 10000)
+	genesis.Params.SignedBlocksWindow = 2
+	genesis.Params.MinSignedPerWindow = staking.BasisPoints
+	genesis.Params.SlashFractionDowntime = 1000
+	delegatorKey, delegatorPub := utils.GenerateKeys()
+	delegator := utils.PublicKeyToAddress(delegatorPub)
+	genesis.Accounts = []state.GenesisAccount{{Address: delegator, Balance: 10000}}
+	c := newTestChain(t, genesis, keys, validators[0])
+
+	c.submit(transaction.TxStake, delegatorKey, &transaction.StakePayload{ValidatorID: validators[1], Amount: 5000}, 0)
+	c.produce()
+	c.produce()
+
+	// Unstaking the full delegation only works before the slash
+	c.submit(transaction.TxUnstake, delegatorKey, &transaction.UnstakePayload{ValidatorID: validators[1], Amount: 5000}, 1)
+	block := c.produce()
+	if len(block.Transactions) != 0 {
+		t.Error("proposed an unstake of stake the block slashes first")
+	}
+	if !c.node.state.Staking.Validators[validators[1]].Jailed {
+		t.Error("offline validator was not jailed")
+	}
+	if c.node.mempool.Size() != 0 {
+		t.Error("the failed unstake stayed in the mempool")
+	}
+}
+
+func TestRestartResumesFromCommittedHeight(t *testing.T) {
+	// A delegation changes the validator set at the end of the first epoch
+	genesis, validators, keys := newTestGenesis(100000, 10000)
+	genesis.Params.EpochLength = 2
+	delegatorKey, delegatorPub := utils.GenerateKeys()
+	genesis.Accounts = []state.GenesisAccount{{Address: utils.PublicKeyToAddress(delegatorPub), Balance: 10000}}
+	c := newTestChain(t, genesis, keys, validators...)
+	genesisSet := c.node.validators.Hash()
+
+	c.submit(transaction.TxStake, delegatorKey, &transaction.StakePayload{ValidatorID: validators[1], Amount: 5000}, 0)
+	c.produce()
+	c.produce()
+	latest := c.produce()
+	if c.node.validators.Hash() == genesisSet {
+		t.Fatal("the validator set did not change")
+	}
+	validatorsHash, lastSetHash := c.node.validators.Hash(), c.node.lastSet.Hash()
+	lastCommit := string(c.node.lastCommit)
+	encoded, err := c.node.state.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.restart()
+	if c.node.latestBlock.Hash != latest.Hash {
+		t.Fatalf("restarted at block %d, want %d", c.node.latestBlock.Index, latest.Index)
+	}
+	if c.node.validators.Hash() != validatorsHash || c.node.lastSet.Hash() != lastSetHash {
+		t.Error("validator sets differ after restart")
+	}
+	if string(c.node.lastCommit) != lastCommit {
+		t.Error("last commit differs after restart")
+	}
+	restored, err := c.node.state.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(restored) != string(encoded) {
+		t.Error("state differs after restart")
+	}
+
+	// The chain continues on top of the restored height
+	c.produce()
+}
+
+func TestCatchUpWithCommittedBlocks(t *testing.T) {
+	genesis, validators, keys := newTestGenesis(100000, 10000)
+	senderKey, senderPub := utils.GenerateKeys()
+	genesis.Accounts = []state.GenesisAccount{{Address: utils.PublicKeyToAddress(senderPub), Balance: 10000}}
+	ahead := newTestChain(t, genesis, keys, validators[0])
+	ahead.submit(transaction.TxStake, senderKey, &transaction.StakePayload{ValidatorID: validators[1], Amount: 5000}, 0)
+	for i := 0; i < 3; i++ {
+		ahead.produce()
+	}
+
+	behind := newTestChain(t, genesis, keys)
+	if err := behind.node.Start(); err != nil {
+		t.Fatal(err)
+	}
+	committed := func(height int) *committedBlock {
+		block, err := ahead.db.GetBlockByHeight(height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		commit, err := ahead.db.GetCommit(height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &committedBlock{Block: block, Commit: commit}
+	}
+
+	// A certificate without a 2/3 majority doesn't commit the block
+	forged := committed(1)
+	forged.Commit.Precommits = nil
+	if err := behind.node.addCommittedBlock("peer", forged); err == nil {
+		t.Fatal("committed a block without precommits")
+	}
+
+	for height := 1; height <= 3; height++ {
+		if err := behind.node.addCommittedBlock("peer", committed(height)); err != nil {
+			t.Fatalf("failed to catch up with block %d: %v", height, err)
+		}
+	}
+	// Blocks we already have are ignored
+	if err := behind.node.addCommittedBlock("peer", committed(2)); err != nil {
+		t.Errorf("a block we already have was rejected: %v", err)
+	}
+
+	if behind.node.latestBlock.Hash != ahead.node.latestBlock.Hash {
+		t.Fatalf("caught up to block %d, want %d", behind.node.latestBlock.Index, ahead.node.latestBlock.Index)
+	}
+	want, err := ahead.node.state.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := behind.node.state.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Error("state differs after catching up")
+	}
+	if height := behind.node.consensus.Height; height != 4 {
+		t.Errorf("consensus is at height %d after catching up, want 4", height)
+	}
+}
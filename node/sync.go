@@ -0,0 +1,111 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"matrix-blockchain/blockchain"
+	"matrix-blockchain/network"
+	"matrix-blockchain/transaction"
+	"time"
+)
+
+// maxBlocksPerRequest bounds the committed blocks sent for one request.
+const maxBlocksPerRequest = 100
+
+// blockRequestInterval is how long to wait for the blocks of a request
+// before asking again.
+const blockRequestInterval = 2 * time.Second
+
+// blockRequest asks a peer for the committed blocks from a height.
+type blockRequest struct {
+	Height int64 `json:"height"`
+}
+
+// committedBlock is a block sent to a peer that fell behind, with the commit
+// certificate proving it was finalized.
+type committedBlock struct {
+	Block  *transaction.Block            `json:"block"`
+	Commit *blockchain.CommitCertificate `json:"commit"`
+}
+
+// blockSync tracks the blocks we asked peers for while catching up.
+type blockSync struct {
+	height      int64     // First height of the last request
+	requestedAt time.Time // When the last request was sent
+}
+
+// checkBehind requests the blocks we are missing when a peer sends consensus
+// messages for a height we haven't reached. Consensus keeps messages for the
+// next height, anything further means the network committed blocks without us.
+func (n *Node) checkBehind(peer string, msg network.Message) {
+	var header struct {
+		Height int64 `json:"height"`
+	}
+	if err := json.Unmarshal(msg.Payload, &header); err != nil {
+		return
+	}
+
+	n.mutex.Lock()
+	next := int64(n.latestBlock.Index) + 1
+	behind := header.Height > next
+	if behind && (next > n.sync.height || time.Since(n.sync.requestedAt) > blockRequestInterval) {
+		n.sync.height, n.sync.requestedAt = next, time.Now()
+	} else {
+		behind = false
+	}
+	n.mutex.Unlock()
+
+	if behind {
+		if err := n.network.SendPayload(peer, network.MsgBlockRequest, &blockRequest{Height: next}); err != nil {
+			fmt.Printf("Failed to request blocks from %s: %v\n", peer, err)
+		}
+	}
+}
+
+// serveBlocks sends a peer the committed blocks it asked for, each with its
+// commit certificate.
+func (n *Node) serveBlocks(peer string, request *blockRequest) error {
+	n.mutex.Lock()
+	latest := int64(n.latestBlock.Index)
+	n.mutex.Unlock()
+
+	if request.Height <= 0 {
+		return fmt.Errorf("invalid block request from height %d", request.Height)
+	}
+	for height := request.Height; height <= latest && height < request.Height+maxBlocksPerRequest; height++ {
+		block, err := n.db.GetBlockByHeight(int(height))
+		if err != nil {
+			return err
+		}
+		commit, err := n.db.GetCommit(int(height))
+		if err != nil {
+			return err
+		}
+		if err := n.network.SendPayload(peer, network.MsgBlock, &committedBlock{Block: block, Commit: commit}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addCommittedBlock commits a block received from a peer if it is the next
+// one, and asks for the blocks in between if it is further ahead. Blocks we
+// already have are ignored.
+func (n *Node) addCommittedBlock(peer string, committed *committedBlock) error {
+	if committed.Block == nil || committed.Commit == nil {
+		return fmt.Errorf("committed block is missing its block or commit")
+	}
+
+	n.mutex.Lock()
+	next := int64(n.latestBlock.Index) + 1
+	n.mutex.Unlock()
+
+	height := int64(committed.Block.Index)
+	if height < next {
+		return nil
+	}
+	if height > next {
+		return n.network.SendPayload(peer, network.MsgBlockRequest, &blockRequest{Height: next})
+	}
+	return n.consensus.AddCommit(committed.Block, committed.Commit)
+}
@@ -0,0 +1,226 @@
+package staking
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+)
+
+// Rewards are accounted lazily, F1-style: each validator keeps the cumulative
+// reward earned per unit of stake at the end of every period, and a period
+// ends whenever one of its delegations changes. A delegator's rewards are the
+// stake it held since its starting period times the growth of that ratio, so
+// allocating a block reward never iterates over delegators.
+
+// rewardPrecision scales the cumulative reward ratios kept per period.
+var rewardPrecision = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+
+// HistoricalReward is the cumulative reward per unit of stake, scaled by
+// rewardPrecision, at the end of a validator's reward period.
+type HistoricalReward struct {
+	Ratio          *big.Int
+	ReferenceCount int // Delegations starting from the period, plus the period that follows it
+}
+
+// initRewards starts the reward bookkeeping of a new validator at period 1.
+func (v *Validator) initRewards() {
+	v.RewardPeriod = 1
+	v.HistoricalRewards = map[uint64]*HistoricalReward{0: {Ratio: new(big.Int), ReferenceCount: 1}}
+	v.RewardStarts = make(map[string]uint64)
+}
+
+// incrementPeriod ends the validator's current reward period, records its
+// cumulative ratio and returns the period that ended. Rewards that don't
+// divide evenly over the stake are carried into the next period, rounding up
+// what the ratio pays out so delegations never receive more than allocated.
+func (v *Validator) incrementPeriod() uint64 {
+	ended := v.RewardPeriod
+	ratio := new(big.Int)
+	if v.StakedAmount > 0 {
+		ratio.Mul(big.NewInt(v.CurrentRewards), rewardPrecision)
+		ratio.Quo(ratio, big.NewInt(v.StakedAmount))
+
+		paid := new(big.Int).Mul(ratio, big.NewInt(v.StakedAmount))
+		paid.Add(paid, new(big.Int).Sub(rewardPrecision, big.NewInt(1)))
+		paid.Quo(paid, rewardPrecision)
+		v.CurrentRewards -= paid.Int64()
+	}
+
+	previous := v.HistoricalRewards[ended-1]
+	v.HistoricalRewards[ended] = &HistoricalReward{Ratio: ratio.Add(ratio, previous.Ratio), ReferenceCount: 1}
+	v.releasePeriod(ended - 1)
+	v.RewardPeriod++
+	return ended
+}
+
+// releasePeriod drops a reference to a period's ratio, pruning it once unused.
+func (v *Validator) releasePeriod(period uint64) {
+	historical := v.HistoricalRewards[period]
+	historical.ReferenceCount--
+	if historical.ReferenceCount == 0 {
+		delete(v.HistoricalRewards, period)
+	}
+}
+
+// delegationRewards returns what a delegation earned from its starting period
+// up to the end of the given period.
+func (v *Validator) delegationRewards(delegator string, ending *big.Int) int64 {
+	start := v.HistoricalRewards[v.RewardStarts[delegator]].Ratio
+	rewards := new(big.Int).Sub(ending, start)
+	rewards.Mul(rewards, big.NewInt(v.Delegators[delegator]))
+	return rewards.Quo(rewards, rewardPrecision).Int64()
+}
+
+// withdrawDelegation moves a delegation's rewards up to the end of the given
+// period into the delegator's reward balance and forgets its starting period.
+func (s *StakingSystem) withdrawDelegation(v *Validator, delegator string, ended uint64) {
+	start, exists := v.RewardStarts[delegator]
+	if !exists {
+		return
+	}
+	if rewards := v.delegationRewards(delegator, v.HistoricalRewards[ended].Ratio); rewards > 0 {
+		s.RewardBalances[delegator] += rewards
+	}
+	v.releasePeriod(start)
+	delete(v.RewardStarts, delegator)
+}
+
+// initializeDelegation makes a delegation earn from the current period on.
+func (v *Validator) initializeDelegation(delegator string) {
+	if v.Delegators[delegator] == 0 {
+		return
+	}
+	previous := v.RewardPeriod - 1
+	v.HistoricalRewards[previous].ReferenceCount++
+	v.RewardStarts[delegator] = previous
+}
+
+// beforeDelegationChange ends the validator's reward period and settles the
+// delegation's rewards before its stake changes; initializeDelegation must
+// follow once the stake is updated. The period ends even for a new
+// delegation, so it can't share in rewards allocated before it existed.
+func (s *StakingSystem) beforeDelegationChange(v *Validator, delegator string) {
+	s.withdrawDelegation(v, delegator, v.incrementPeriod())
+}
+
+// beforeSlash settles every delegation of a validator, whose stakes are all
+// about to change; initializeDelegations must follow the slash.
+func (s *StakingSystem) beforeSlash(v *Validator) {
+	ended := v.incrementPeriod()
+	for delegator := range v.Delegators {
+		s.withdrawDelegation(v, delegator, ended)
+	}
+}
+
+// initializeDelegations restarts every delegation of a validator.
+func (v *Validator) initializeDelegations() {
+	for delegator := range v.Delegators {
+		v.initializeDelegation(delegator)
+	}
+}
+
+// AllocateRewards credits a reward to a validator. The validator keeps its
+// commission and the rest accrues to its delegations in proportion to their
+// stake, its own self-bond included.
+func (s *StakingSystem) AllocateRewards(validatorID string, amount int64) error {
+	validator, exists := s.Validators[validatorID]
+	if !exists {
+		return errors.New("validator does not exist")
+	}
+	if amount < 0 {
+		return errors.New("reward amount must not be negative")
+	}
+
+	commission := amount * validator.Commission.Rate / BasisPoints
+	if validator.StakedAmount == 0 {
+		commission = amount
+	}
+	validator.Rewards += commission
+	validator.CurrentRewards += amount - commission
+	s.OutstandingRewards += amount
+	return nil
+}
+
+// DistributeRewards splits a reward between validators in proportion to
+// their stake and allocates each share with AllocateRewards. The remainder of
+// the division goes to the first validator with stake, in the given order.
+// It returns the amount allocated, which is zero if none of them has stake.
+func (s *StakingSystem) DistributeRewards(totalReward int64, validatorIDs []string) int64 {
+	var totalStake int64
+	var recipients []*Validator
+	for _, id := range validatorIDs {
+		if validator, exists := s.Validators[id]; exists && validator.StakedAmount > 0 {
+			recipients = append(recipients, validator)
+			totalStake += validator.StakedAmount
+		}
+	}
+	if len(recipients) == 0 {
+		return 0
+	}
+
+	shares := make([]int64, len(recipients))
+	remainder := totalReward
+	for i, validator := range recipients {
+		share := new(big.Int).Mul(big.NewInt(totalReward), big.NewInt(validator.StakedAmount))
+		shares[i] = share.Quo(share, big.NewInt(totalStake)).Int64()
+		remainder -= shares[i]
+	}
+	shares[0] += remainder
+
+	for i, validator := range recipients {
+		s.AllocateRewards(validator.ID, shares[i])
+	}
+	return totalReward
+}
+
+// PendingRewards returns the rewards a delegator could withdraw now: the
+// rewards of its delegations, its settled reward balance and, for a
+// validator, its commission.
+func (s *StakingSystem) PendingRewards(delegator string) int64 {
+	total := s.RewardBalances[delegator]
+	for _, validator := range s.Validators {
+		if validator.ID == delegator {
+			total += validator.Rewards
+		}
+		if _, exists := validator.RewardStarts[delegator]; !exists {
+			continue
+		}
+
+		// Ratio the current period would end with if it ended now
+		ending := new(big.Int)
+		if validator.StakedAmount > 0 {
+			ending.Mul(big.NewInt(validator.CurrentRewards), rewardPrecision)
+			ending.Quo(ending, big.NewInt(validator.StakedAmount))
+		}
+		ending.Add(ending, validator.HistoricalRewards[validator.RewardPeriod-1].Ratio)
+		total += validator.delegationRewards(delegator, ending)
+	}
+	return total
+}
+
+// WithdrawRewards settles all of a delegator's rewards, and its commission if
+// it is a validator, and returns the amount to pay out.
+func (s *StakingSystem) WithdrawRewards(delegator string) int64 {
+	ids := make([]string, 0, len(s.Validators))
+	for id := range s.Validators {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		validator := s.Validators[id]
+		if _, exists := validator.RewardStarts[delegator]; exists {
+			s.beforeDelegationChange(validator, delegator)
+			validator.initializeDelegation(delegator)
+		}
+	}
+
+	total := s.RewardBalances[delegator]
+	delete(s.RewardBalances, delegator)
+	if validator, exists := s.Validators[delegator]; exists {
+		total += validator.Rewards
+		validator.Rewards = 0
+	}
+	s.OutstandingRewards -= total
+	return total
+}
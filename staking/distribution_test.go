@@ -0,0 +1,113 @@
+package staking
+
+import (
+	"matrix-blockchain/utils"
+	"testing"
+)
+
+// newTestValidator registers a validator without commission.
+func newTestValidator(t *testing.T, s *StakingSystem, id string, selfBond int64) {
+	t.Helper()
+	_, pub := utils.GenerateKeys()
+	commission := Commission{Rate: 0, MaxChangeRate: 100}
+	if err := s.RegisterValidator(id, selfBond, utils.EncodePublicKey(pub), commission, Description{Moniker: id}, 0); err != nil {
+		t.Fatalf("RegisterValidator(%s): %v", id, err)
+	}
+}
+
+func TestNewDelegationDoesNotEarnEarlierRewards(t *testing.T) {
+	s := NewStakingSystem()
+	newTestValidator(t, s, "val", 10000)
+
+	if err := s.AllocateRewards("val", 100000); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Stake("newcomer", "val", 90000); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.PendingRewards("newcomer"); got != 0 {
+		t.Errorf("newcomer pending rewards = %d, want 0", got)
+	}
+	if got := s.WithdrawRewards("newcomer"); got != 0 {
+		t.Errorf("newcomer withdrew %d, want 0", got)
+	}
+	if got := s.WithdrawRewards("val"); got != 100000 {
+		t.Errorf("validator withdrew %d, want 100000", got)
+	}
+	if s.OutstandingRewards != 0 {
+		t.Errorf("outstanding rewards = %d, want 0", s.OutstandingRewards)
+	}
+}
+
+func TestRewardsAccrueInProportionToStake(t *testing.T) {
+	s := NewStakingSystem()
+	newTestValidator(t, s, "val", 10000)
+	if err := s.Stake("alice", "val", 30000); err != nil {
+		t.Fatal(err)
+	}
+
+	// 40,000 split 1:3 between the validator and alice
+	if err := s.AllocateRewards("val", 40000); err != nil {
+		t.Fatal(err)
+	}
+	// bob joins; the next 80,000 are split 1:3:4
+	if err := s.Stake("bob", "val", 40000); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AllocateRewards("val", 80000); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int64{"val": 10000 + 10000, "alice": 30000 + 30000, "bob": 40000}
+	for delegator, amount := range want {
+		if got := s.PendingRewards(delegator); got != amount {
+			t.Errorf("%s pending rewards = %d, want %d", delegator, got, amount)
+		}
+	}
+	var withdrawn int64
+	for _, delegator := range []string{"alice", "bob", "val"} {
+		withdrawn += s.WithdrawRewards(delegator)
+	}
+	if withdrawn != 120000 || s.OutstandingRewards != 0 {
+		t.Errorf("withdrew %d with %d outstanding, want 120000 and 0", withdrawn, s.OutstandingRewards)
+	}
+}
+
+func TestRewardsAfterSlashNeverExceedAllocation(t *testing.T) {
+	s := NewStakingSystem()
+	newTestValidator(t, s, "val", 10000)
+	if err := s.Stake("alice", "val", 7001); err != nil {
+		t.Fatal(err)
+	}
+
+	var allocated int64
+	for i := int64(1); i <= 50; i++ {
+		amount := 997 * i
+		if err := s.AllocateRewards("val", amount); err != nil {
+			t.Fatal(err)
+		}
+		allocated += amount
+		if i == 20 {
+			if _, err := s.Slash("val", 0, 500); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if i%7 == 0 {
+			if err := s.Stake("carol", "val", 333*i); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var withdrawn int64
+	for _, delegator := range []string{"alice", "carol", "val"} {
+		withdrawn += s.WithdrawRewards(delegator)
+	}
+	if withdrawn > allocated {
+		t.Errorf("withdrew %d, more than the %d allocated", withdrawn, allocated)
+	}
+	if withdrawn+s.OutstandingRewards != allocated {
+		t.Errorf("withdrawn %d plus outstanding %d != allocated %d", withdrawn, s.OutstandingRewards, allocated)
+	}
+}
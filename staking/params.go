@@ -0,0 +1,107 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+)
+
+// BasisPoints is the denominator of fractions expressed in basis points.
+const BasisPoints = 10000
+
+// Params are the chain parameters governing staking and governance, set in
+// genesis.
+type Params struct {
+	SlashFractionDoubleSign int64 `json:"slash_fraction_double_sign"` // Stake burned for double-signing, in basis points
+	MaxEvidenceAge          int64 `json:"max_evidence_age"`           // Blocks after which an infraction can no longer be punished
+	SignedBlocksWindow      int64 `json:"signed_blocks_window"`       // Blocks over which liveness is measured
+	MinSignedPerWindow      int64 `json:"min_signed_per_window"`      // Share of the window a validator must sign, in basis points
+	SlashFractionDowntime   int64 `json:"slash_fraction_downtime"`    // Stake burned for downtime, in basis points
+	DowntimeJailDuration    int64 `json:"downtime_jail_duration"`     // Blocks a validator stays jailed for downtime
+	EpochLength             int64 `json:"epoch_length"`               // Blocks between validator set updates
+	UnbondingPeriod         int64 `json:"unbonding_period"`           // Blocks withdrawn stake stays locked and slashable
+	MinSelfBond             int64 `json:"min_self_bond"`              // Smallest self-bond a validator may register or run with
+	MaxValidators           int64 `json:"max_validators"`             // Size of the active set
+	MinValidatorStake       int64 `json:"min_validator_stake"`        // Smallest total stake that can be elected
+	VotingPeriod            int64 `json:"voting_period"`              // Blocks a governance proposal is open for voting
+	Quorum                  int64 `json:"quorum"`                     // Share of bonded stake that must vote, in basis points
+	Threshold               int64 `json:"threshold"`                  // Share of yes among yes and no votes needed to pass, in basis points
+	ValidatorShare          int64 `json:"validator_share"`            // Share of block rewards paid to validators, in basis points
+	BurnShare               int64 `json:"burn_share"`                 // Share of block rewards burned, in basis points
+	ResearchShare           int64 `json:"research_share"`             // Share of block rewards paid to the research treasury, in basis points
+}
+
+// DefaultParams returns the parameters used when genesis doesn't set them.
+func DefaultParams() Params {
+	return Params{
+		SlashFractionDoubleSign: 500, // 5%
+		MaxEvidenceAge:          100000,
+		SignedBlocksWindow:      100,
+		MinSignedPerWindow:      5000, // 50%
+		SlashFractionDowntime:   1,    // 0.01%
+		DowntimeJailDuration:    600,
+		EpochLength:             100,
+		UnbondingPeriod:         100000,
+		MinSelfBond:             10000,
+		MaxValidators:           100,
+		MinValidatorStake:       10000,
+		VotingPeriod:            17280, // About a day at 5s blocks
+		Quorum:                  3340,  // 33.4%
+		Threshold:               5000,  // More than 50%
+		ValidatorShare:          5000,
+		BurnShare:               2500,
+		ResearchShare:           2500,
+	}
+}
+
+// Validate checks that the parameters are within range.
+func (p Params) Validate() error {
+	if p.SlashFractionDoubleSign < 0 || p.SlashFractionDoubleSign > BasisPoints {
+		return fmt.Errorf("double-sign slash fraction must be between 0 and %d basis points", BasisPoints)
+	}
+	if p.MaxEvidenceAge <= 0 {
+		return errors.New("max evidence age must be positive")
+	}
+	if p.SignedBlocksWindow <= 0 {
+		return errors.New("signed blocks window must be positive")
+	}
+	if p.MinSignedPerWindow < 0 || p.MinSignedPerWindow > BasisPoints {
+		return fmt.Errorf("min signed per window must be between 0 and %d basis points", BasisPoints)
+	}
+	if p.SlashFractionDowntime < 0 || p.SlashFractionDowntime > BasisPoints {
+		return fmt.Errorf("downtime slash fraction must be between 0 and %d basis points", BasisPoints)
+	}
+	if p.DowntimeJailDuration < 0 {
+		return errors.New("downtime jail duration cannot be negative")
+	}
+	if p.EpochLength <= 0 {
+		return errors.New("epoch length must be positive")
+	}
+	if p.UnbondingPeriod < p.MaxEvidenceAge {
+		return errors.New("unbonding period must be at least the max evidence age")
+	}
+	if p.MinSelfBond <= 0 {
+		return errors.New("min self-bond must be positive")
+	}
+	if p.MaxValidators <= 0 {
+		return errors.New("max validators must be positive")
+	}
+	if p.MinValidatorStake <= 0 {
+		return errors.New("min validator stake must be positive")
+	}
+	if p.VotingPeriod <= 0 {
+		return errors.New("voting period must be positive")
+	}
+	if p.Quorum < 0 || p.Quorum > BasisPoints {
+		return fmt.Errorf("quorum must be between 0 and %d basis points", BasisPoints)
+	}
+	if p.Threshold < 0 || p.Threshold > BasisPoints {
+		return fmt.Errorf("threshold must be between 0 and %d basis points", BasisPoints)
+	}
+	if p.ValidatorShare < 0 || p.BurnShare < 0 || p.ResearchShare < 0 {
+		return errors.New("reward shares cannot be negative")
+	}
+	if p.ValidatorShare+p.BurnShare+p.ResearchShare != BasisPoints {
+		return fmt.Errorf("reward shares must add up to %d basis points", BasisPoints)
+	}
+	return nil
+}
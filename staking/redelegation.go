@@ -0,0 +1,119 @@
+package staking
+
+import (
+	"errors"
+	"sort"
+)
+
+// RedelegationEntry is stake moved from one validator to another. Until
+// CompletionHeight the moved stake still answers for the source validator's
+// infractions, and it cannot be redelegated again.
+type RedelegationEntry struct {
+	Delegator        string
+	SrcValidatorID   string
+	DstValidatorID   string
+	Amount           int64
+	CreationHeight   int64 // Height of the block that moved the stake
+	CompletionHeight int64 // Height from which the entry is dropped
+}
+
+// Redelegate moves stake from one validator to another without unbonding it.
+// Stake that arrived at the source validator through a redelegation that has
+// not matured yet cannot be moved on, so a delegator can't hop away from
+// every validator it might be slashed for.
+func (s *StakingSystem) Redelegate(delegator string, srcValidatorID string, dstValidatorID string, amount int64, height int64) error {
+	if amount <= 0 {
+		return errors.New("redelegate amount must be positive")
+	}
+	if srcValidatorID == dstValidatorID {
+		return errors.New("cannot redelegate to the same validator")
+	}
+	src, exists := s.Validators[srcValidatorID]
+	if !exists {
+		return errors.New("source validator does not exist")
+	}
+	if src.Delegators[delegator] < amount {
+		return errors.New("not enough stake to redelegate")
+	}
+	if _, exists := s.Validators[dstValidatorID]; !exists {
+		return errors.New("destination validator does not exist")
+	}
+	for _, entry := range s.Redelegations {
+		if entry.Delegator == delegator && entry.DstValidatorID == srcValidatorID {
+			return errors.New("stake redelegated to the source validator has not matured yet")
+		}
+	}
+
+	// Both validators were checked above, so neither step can fail
+	if err := s.Unstake(delegator, srcValidatorID, amount); err != nil {
+		return err
+	}
+	if err := s.Stake(delegator, dstValidatorID, amount); err != nil {
+		return err
+	}
+
+	s.Redelegations = append(s.Redelegations, &RedelegationEntry{
+		Delegator:        delegator,
+		SrcValidatorID:   srcValidatorID,
+		DstValidatorID:   dstValidatorID,
+		Amount:           amount,
+		CreationHeight:   height,
+		CompletionHeight: height + s.Params.UnbondingPeriod,
+	})
+	return nil
+}
+
+// CompleteRedelegations drops the redelegation entries that have matured at height.
+func (s *StakingSystem) CompleteRedelegations(height int64) {
+	var pending []*RedelegationEntry
+	for _, entry := range s.Redelegations {
+		if entry.CompletionHeight > height {
+			pending = append(pending, entry)
+		}
+	}
+	s.Redelegations = pending
+}
+
+// PendingRedelegations returns a delegator's redelegation entries, soonest first.
+func (s *StakingSystem) PendingRedelegations(delegator string) []RedelegationEntry {
+	var entries []RedelegationEntry
+	for _, entry := range s.Redelegations {
+		if entry.Delegator == delegator {
+			entries = append(entries, *entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CompletionHeight < entries[j].CompletionHeight
+	})
+	return entries
+}
+
+// slashRedelegations burns a fraction of the stake redelegated away from a
+// validator since the infraction height. The stake is taken from the
+// destination validator, as far as the delegator still has it bonded there.
+// It returns the amount burned.
+func (s *StakingSystem) slashRedelegations(validatorID string, infractionHeight int64, fraction int64) int64 {
+	var burned int64
+	for _, entry := range s.Redelegations {
+		if entry.SrcValidatorID != validatorID || entry.CreationHeight < infractionHeight {
+			continue
+		}
+		dst, exists := s.Validators[entry.DstValidatorID]
+		if !exists {
+			continue
+		}
+		cut := entry.Amount * fraction / BasisPoints
+		if bonded := dst.Delegators[entry.Delegator]; cut > bonded {
+			cut = bonded
+		}
+		s.beforeDelegationChange(dst, entry.Delegator)
+		dst.Delegators[entry.Delegator] -= cut
+		if dst.Delegators[entry.Delegator] == 0 {
+			delete(dst.Delegators, entry.Delegator)
+		}
+		dst.StakedAmount -= cut
+		dst.initializeDelegation(entry.Delegator)
+		burned += cut
+	}
+	return burned
+}
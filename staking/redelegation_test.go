@@ -0,0 +1,93 @@
+package staking
+
+import "testing"
+
+// newTestRedelegation moves 400 of a delegator's 1000 staked on "src" to
+// "dst" at height 5, with an unbonding period of 10 blocks.
+func newTestRedelegation(t *testing.T) *StakingSystem {
+	t.Helper()
+	s := NewStakingSystem()
+	s.Params.UnbondingPeriod = 10
+	for _, id := range []string{"src", "dst", "third"} {
+		newTestValidator(t, s, id, 10000)
+	}
+	if err := s.Stake("delegator", "src", 1000); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Redelegate("delegator", "src", "dst", 400, 5); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestRedelegationSlashPropagation(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		infraction int64
+		unstaked   int64 // Stake the delegator withdrew from dst before the slash
+		matured    bool  // Whether the redelegation completed before the slash
+		remaining  int64 // Delegation left on dst after a 10% slash of src
+		burned     int64
+	}{
+		{"redelegated after the infraction", 5, 0, false, 360, 1100},
+		{"redelegated before the infraction", 6, 0, false, 400, 1060},
+		{"capped at what is still bonded", 5, 390, false, 0, 1070},
+		{"matured", 5, 0, true, 400, 1060},
+	} {
+		s := newTestRedelegation(t)
+		if tc.unstaked > 0 {
+			if err := s.Unstake("delegator", "dst", tc.unstaked); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if tc.matured {
+			s.CompleteRedelegations(15)
+		}
+
+		// 10% of the 10600 bonded to src is burned in every case
+		burned, err := s.Slash("src", tc.infraction, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if burned != tc.burned {
+			t.Errorf("%s: burned %d, want %d", tc.name, burned, tc.burned)
+		}
+		dst := s.Validators["dst"]
+		if got := dst.Delegators["delegator"]; got != tc.remaining {
+			t.Errorf("%s: delegation on dst is %d, want %d", tc.name, got, tc.remaining)
+		}
+		if want := 10000 + tc.remaining; dst.StakedAmount != want {
+			t.Errorf("%s: dst stake is %d, want %d", tc.name, dst.StakedAmount, want)
+		}
+		if _, exists := dst.Delegators["delegator"]; exists && tc.remaining == 0 {
+			t.Errorf("%s: empty delegation left on dst", tc.name)
+		}
+	}
+}
+
+func TestRedelegateRules(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		src, dst string
+		amount   int64
+		height   int64 // Height of the redelegation, the first one completes at 15
+		valid    bool
+	}{
+		{"same validator", "src", "src", 100, 6, false},
+		{"more than staked", "src", "third", 601, 6, false},
+		{"unknown destination", "src", "nobody", 100, 6, false},
+		{"rest of the source stake", "src", "third", 600, 6, true},
+		{"hop before maturity", "dst", "third", 100, 14, false},
+		{"hop after maturity", "dst", "third", 100, 15, true},
+	} {
+		s := newTestRedelegation(t)
+		s.CompleteRedelegations(tc.height)
+		err := s.Redelegate("delegator", tc.src, tc.dst, tc.amount, tc.height)
+		if tc.valid && err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: redelegation accepted", tc.name)
+		}
+	}
+}
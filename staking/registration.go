@@ -0,0 +1,152 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+	"matrix-blockchain/utils"
+)
+
+// Limits on the validator description fields.
+const (
+	MaxMonikerLength = 70
+	MaxWebsiteLength = 140
+	MaxContactLength = 140
+)
+
+// Description is the public metadata of a validator.
+type Description struct {
+	Moniker string `json:"moniker"`
+	Website string `json:"website"`
+	Contact string `json:"contact"`
+}
+
+// Commission is the share of its delegators' rewards a validator keeps.
+type Commission struct {
+	Rate          int64 `json:"rate"`            // Current commission, in basis points
+	MaxChangeRate int64 `json:"max_change_rate"` // Largest change of Rate per edit, in basis points
+	UpdateHeight  int64 `json:"update_height"`   // Height the rate was last set at
+}
+
+// Validate checks the description fields.
+func (d Description) Validate() error {
+	if d.Moniker == "" {
+		return errors.New("validator moniker is required")
+	}
+	if len(d.Moniker) > MaxMonikerLength {
+		return fmt.Errorf("validator moniker longer than %d bytes", MaxMonikerLength)
+	}
+	if len(d.Website) > MaxWebsiteLength {
+		return fmt.Errorf("validator website longer than %d bytes", MaxWebsiteLength)
+	}
+	if len(d.Contact) > MaxContactLength {
+		return fmt.Errorf("validator contact longer than %d bytes", MaxContactLength)
+	}
+	return nil
+}
+
+// Validate checks the commission rates.
+func (c Commission) Validate() error {
+	if c.Rate < 0 || c.Rate > BasisPoints {
+		return errors.New("commission rate out of range")
+	}
+	if c.MaxChangeRate < 0 || c.MaxChangeRate > BasisPoints {
+		return errors.New("max commission change rate out of range")
+	}
+	return nil
+}
+
+// SelfBond returns the stake the validator bonded to itself.
+func (v *Validator) SelfBond() int64 {
+	return v.Delegators[v.ID]
+}
+
+// consensusKeyOwner returns the ID of the validator registered with a
+// consensus key. A key belongs to at most one validator, so a node always
+// signs its votes as the validator it registered.
+func (s *StakingSystem) consensusKeyOwner(pubKey []byte) (string, bool) {
+	if s.consensusKeys == nil {
+		s.consensusKeys = make(map[string]string, len(s.Validators))
+		for id, validator := range s.Validators {
+			s.consensusKeys[string(validator.ConsensusPubKey)] = id
+		}
+	}
+	id, exists := s.consensusKeys[string(pubKey)]
+	return id, exists
+}
+
+// RegisterValidator creates a validator bonded with at least MinSelfBond of
+// its own stake. Only registered validators accept delegations. The
+// consensus key must not be registered to another validator.
+func (s *StakingSystem) RegisterValidator(validatorID string, selfBond int64, pubKey []byte, commission Commission, description Description, height int64) error {
+	if _, exists := s.Validators[validatorID]; exists {
+		return fmt.Errorf("validator %s is already registered", validatorID)
+	}
+	if selfBond < s.Params.MinSelfBond {
+		return fmt.Errorf("self-bond must be at least %d", s.Params.MinSelfBond)
+	}
+	if _, err := utils.DecodePublicKey(pubKey); err != nil {
+		return fmt.Errorf("invalid consensus key: %v", err)
+	}
+	if owner, exists := s.consensusKeyOwner(pubKey); exists {
+		return fmt.Errorf("consensus key is already registered to validator %s", owner)
+	}
+	if err := commission.Validate(); err != nil {
+		return err
+	}
+	if err := description.Validate(); err != nil {
+		return err
+	}
+
+	commission.UpdateHeight = height
+	validator := &Validator{
+		ID:              validatorID,
+		Delegators:      make(map[string]int64),
+		ConsensusPubKey: pubKey,
+		Description:     description,
+		Commission:      commission,
+	}
+	validator.initRewards()
+	s.beforeDelegationChange(validator, validatorID)
+	validator.StakedAmount = selfBond
+	validator.Delegators[validatorID] = selfBond
+	validator.initializeDelegation(validatorID)
+	s.Validators[validatorID] = validator
+	s.consensusKeys[string(pubKey)] = validatorID
+	return nil
+}
+
+// EditValidator replaces a validator's description and, when commissionRate
+// is not nil, its commission rate. The rate may change by at most the
+// validator's MaxChangeRate, once per epoch.
+func (s *StakingSystem) EditValidator(validatorID string, description Description, commissionRate *int64, height int64) error {
+	validator, exists := s.Validators[validatorID]
+	if !exists {
+		return errors.New("validator does not exist")
+	}
+	if err := description.Validate(); err != nil {
+		return err
+	}
+
+	commission := validator.Commission
+	if commissionRate != nil && *commissionRate != commission.Rate {
+		if height-commission.UpdateHeight < s.Params.EpochLength {
+			return fmt.Errorf("commission can't change again before height %d", commission.UpdateHeight+s.Params.EpochLength)
+		}
+		change := *commissionRate - commission.Rate
+		if change < 0 {
+			change = -change
+		}
+		if change > commission.MaxChangeRate {
+			return fmt.Errorf("commission can change by at most %d basis points", commission.MaxChangeRate)
+		}
+		commission.Rate = *commissionRate
+		commission.UpdateHeight = height
+		if err := commission.Validate(); err != nil {
+			return err
+		}
+	}
+
+	validator.Description = description
+	validator.Commission = commission
+	return nil
+}
@@ -0,0 +1,40 @@
+package staking
+
+import (
+	"encoding/json"
+	"matrix-blockchain/utils"
+	"testing"
+)
+
+func TestConsensusKeysAreUnique(t *testing.T) {
+	s := NewStakingSystem()
+	_, pub := utils.GenerateKeys()
+	key := utils.EncodePublicKey(pub)
+	description := Description{Moniker: "validator"}
+	if err := s.RegisterValidator("MRX-victim", 10000, key, Commission{}, description, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// The index survives copies and is rebuilt for decoded states
+	decoded := NewStakingSystem()
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	for name, system := range map[string]*StakingSystem{"original": s, "copy": s.Copy(), "decoded": decoded} {
+		if err := system.RegisterValidator("MRX-attacker", 10000, key, Commission{}, description, 1); err == nil {
+			t.Errorf("%s: registered another validator with the victim's consensus key", name)
+		}
+		if _, exists := system.Validators["MRX-attacker"]; exists {
+			t.Errorf("%s: rejected registration left a validator behind", name)
+		}
+	}
+
+	_, other := utils.GenerateKeys()
+	if err := s.RegisterValidator("MRX-other", 10000, utils.EncodePublicKey(other), Commission{}, description, 1); err != nil {
+		t.Errorf("validator with its own key rejected: %v", err)
+	}
+}
@@ -1,9 +1,7 @@
 package staking
 
-import (
-	"fmt"
-)
-
+// Reward is a block reward split between validators, burning and the
+// research treasury.
 type Reward struct {
 	TotalAmount        int64
 	BurnAmount         int64
@@ -11,19 +9,23 @@ type Reward struct {
 	ResearchFundAmount int64
 }
 
-func DistributeValidatorReward(totalRewards int64) Reward {
-	burn := totalRewards * 25 / 100
-	researchFund := totalRewards * 25 / 100
-	validator := totalRewards * 50 / 100
+// SplitReward splits a reward by the reward split parameters. The burn and
+// research shares are rounded down and the validators receive the rest, so
+// the parts always add up to the total.
+func (p Params) SplitReward(totalRewards int64) Reward {
+	burn := share(totalRewards, p.BurnShare)
+	researchFund := share(totalRewards, p.ResearchShare)
 
-	reward := Reward{
+	return Reward{
 		TotalAmount:        totalRewards,
 		BurnAmount:         burn,
 		ResearchFundAmount: researchFund,
-		ValidatorAmount:    validator,
+		ValidatorAmount:    totalRewards - burn - researchFund,
 	}
+}
 
-	fmt.Printf("Distributed %d to validator, %d burned, %d to research fund\n", validator, burn, researchFund)
-
-	return reward
+// share returns a fraction (in basis points) of an amount, rounded down,
+// without overflowing for large amounts.
+func share(amount int64, fraction int64) int64 {
+	return amount/BasisPoints*fraction + amount%BasisPoints*fraction/BasisPoints
 }
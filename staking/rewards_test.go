@@ -0,0 +1,64 @@
+package staking
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSplitRewardReconciles(t *testing.T) {
+	for _, shares := range [][3]int64{
+		{5000, 2500, 2500},
+		{3333, 3333, 3334},
+		{10000, 0, 0},
+		{0, 9999, 1},
+		{1, 1, 9998},
+	} {
+		params := DefaultParams()
+		params.ValidatorShare, params.BurnShare, params.ResearchShare = shares[0], shares[1], shares[2]
+		if err := params.Validate(); err != nil {
+			t.Fatalf("shares %v: %v", shares, err)
+		}
+
+		for _, total := range []int64{0, 1, 2, 3, 7, 9999, 10001, 123456789, math.MaxInt64} {
+			reward := params.SplitReward(total)
+			if reward.ValidatorAmount+reward.BurnAmount+reward.ResearchFundAmount != total || reward.TotalAmount != total {
+				t.Errorf("shares %v: split of %d is %+v", shares, total, reward)
+			}
+			if reward.BurnAmount < 0 || reward.ResearchFundAmount < 0 || reward.ValidatorAmount < 0 {
+				t.Errorf("shares %v: negative part in split of %d: %+v", shares, total, reward)
+			}
+			// Only the validators' part receives the remainder
+			if want := total / BasisPoints * shares[1]; reward.BurnAmount < want {
+				t.Errorf("shares %v: burn of %d is %d, want at least %d", shares, total, reward.BurnAmount, want)
+			}
+		}
+	}
+}
+
+func TestSplitRewardDefault(t *testing.T) {
+	reward := DefaultParams().SplitReward(1003)
+	if reward.BurnAmount != 250 || reward.ResearchFundAmount != 250 || reward.ValidatorAmount != 503 {
+		t.Errorf("default split of 1003 is %+v, want 503/250/250", reward)
+	}
+}
+
+func TestRewardSharesMustAddUp(t *testing.T) {
+	params := DefaultParams()
+	params.BurnShare++
+	if params.Validate() == nil {
+		t.Error("shares adding up to more than 100% accepted")
+	}
+	params.ValidatorShare -= 2
+	if params.Validate() == nil {
+		t.Error("shares adding up to less than 100% accepted")
+	}
+	params = DefaultParams()
+	params.ValidatorShare, params.BurnShare, params.ResearchShare = 7500, 0, 2500
+	if err := params.Validate(); err != nil {
+		t.Errorf("valid shares rejected: %v", err)
+	}
+	params.BurnShare, params.ResearchShare = -1, 2501
+	if params.Validate() == nil {
+		t.Error("negative share accepted")
+	}
+}
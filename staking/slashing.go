@@ -0,0 +1,82 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// JailedForever marks a validator that can never unjail, e.g. after double-signing.
+const JailedForever = math.MaxInt64
+
+// Slash burns a fraction (in basis points) of the stake bonded to a
+// validator, its own and its delegators' alike, and of the stake that started
+// unbonding or was redelegated from it at or after the infraction height. It
+// returns the amount burned.
+func (s *StakingSystem) Slash(validatorID string, infractionHeight int64, fraction int64) (int64, error) {
+	validator, exists := s.Validators[validatorID]
+	if !exists {
+		return 0, errors.New("validator does not exist")
+	}
+	if fraction < 0 || fraction > BasisPoints {
+		return 0, errors.New("slash fraction out of range")
+	}
+
+	s.beforeSlash(validator)
+	var burned int64
+	for delegator, amount := range validator.Delegators {
+		cut := amount * fraction / BasisPoints
+		validator.Delegators[delegator] -= cut
+		if validator.Delegators[delegator] == 0 {
+			delete(validator.Delegators, delegator)
+		}
+		burned += cut
+	}
+	validator.StakedAmount -= burned
+	validator.initializeDelegations()
+
+	burned += s.slashUnbondings(validatorID, infractionHeight, fraction)
+	burned += s.slashRedelegations(validatorID, infractionHeight, fraction)
+	return burned, nil
+}
+
+// Jail removes a validator from the validator set until the given height.
+// A validator that is already jailed keeps the later release height.
+func (s *StakingSystem) Jail(validatorID string, until int64) error {
+	validator, exists := s.Validators[validatorID]
+	if !exists {
+		return errors.New("validator does not exist")
+	}
+
+	if !validator.Jailed || until > validator.JailedUntil {
+		validator.JailedUntil = until
+	}
+	validator.Jailed = true
+	return nil
+}
+
+// Unjail returns a jailed validator to the validator set once its jail time
+// has passed at the given height. The validator's self-bond must still meet
+// MinSelfBond.
+func (s *StakingSystem) Unjail(validatorID string, height int64) error {
+	validator, exists := s.Validators[validatorID]
+	if !exists {
+		return errors.New("validator does not exist")
+	}
+	if !validator.Jailed {
+		return errors.New("validator is not jailed")
+	}
+	if validator.JailedUntil == JailedForever {
+		return errors.New("validator is jailed permanently")
+	}
+	if height < validator.JailedUntil {
+		return fmt.Errorf("validator is jailed until height %d", validator.JailedUntil)
+	}
+	if validator.SelfBond() < s.Params.MinSelfBond {
+		return fmt.Errorf("validator self-bond is below the minimum of %d", s.Params.MinSelfBond)
+	}
+
+	validator.Jailed = false
+	validator.JailedUntil = 0
+	return nil
+}
@@ -3,31 +3,108 @@ package staking
 import (
 	"errors"
 	"fmt"
+	"math/big"
 	"sort"
 )
 
 // Validator represents a validator in the DPoS system.
 type Validator struct {
-	ID           string           // Validator's unique identifier (address)
-	StakedAmount int64            // Total tokens staked by this validator
-	Delegators   map[string]int64 // Map of delegators and their staked amounts
+	ID              string           // Validator's unique identifier (address)
+	StakedAmount    int64            // Total tokens staked by this validator
+	Delegators      map[string]int64 // Map of delegators and their staked amounts
+	Rewards         int64            // Commission earned and not yet withdrawn
+	ConsensusPubKey []byte           // Encoded public key that signs the validator's consensus votes
+	Jailed          bool             // Jailed validators are excluded from the validator set
+	JailedUntil     int64            // Height from which a jailed validator may unjail
+	Description     Description      // Public metadata
+	Commission      Commission       // Share of delegator rewards kept by the validator
+
+	RewardPeriod      uint64                       // Current reward period
+	CurrentRewards    int64                        // Delegator rewards accrued in the current period
+	HistoricalRewards map[uint64]*HistoricalReward // Cumulative reward ratios of past periods still referenced
+	RewardStarts      map[string]uint64            // Period each delegation earns rewards from
 }
 
-// StakingSystem manages staking operations.
+// StakingSystem is the registry of validators and their delegations. It is
+// part of the chain state, so it is rebuilt identically by replaying blocks.
 type StakingSystem struct {
-	Validators    map[string]*Validator // Active validators
-	MaxValidators int                   // Maximum number of validators
+	Validators    map[string]*Validator // Registered validators
+	Params        Params                // Chain parameters
+	Unbondings    []*UnbondingEntry     // Withdrawn stake waiting to be released, in queue order
+	Redelegations []*RedelegationEntry  // Moved stake still slashable for its source validator
+
+	RewardBalances     map[string]int64 // Settled delegation rewards not yet withdrawn, per delegator
+	OutstandingRewards int64            // Rewards and commission allocated and not yet withdrawn
+
+	consensusKeys map[string]string // Validator ID by consensus key, built on first use
 }
 
 // NewStakingSystem initializes a staking system.
-func NewStakingSystem(maxValidators int) *StakingSystem {
+func NewStakingSystem() *StakingSystem {
 	return &StakingSystem{
-		Validators:    make(map[string]*Validator),
-		MaxValidators: maxValidators,
+		Validators:     make(map[string]*Validator),
+		Params:         DefaultParams(),
+		RewardBalances: make(map[string]int64),
+	}
+}
+
+// Copy returns a deep copy of the staking system.
+func (s *StakingSystem) Copy() *StakingSystem {
+	c := NewStakingSystem()
+	c.Params = s.Params
+	for id, validator := range s.Validators {
+		v := &Validator{
+			ID:              validator.ID,
+			StakedAmount:    validator.StakedAmount,
+			Delegators:      make(map[string]int64, len(validator.Delegators)),
+			Rewards:         validator.Rewards,
+			ConsensusPubKey: validator.ConsensusPubKey,
+			Jailed:          validator.Jailed,
+			JailedUntil:     validator.JailedUntil,
+			Description:     validator.Description,
+			Commission:      validator.Commission,
+
+			RewardPeriod:      validator.RewardPeriod,
+			CurrentRewards:    validator.CurrentRewards,
+			HistoricalRewards: make(map[uint64]*HistoricalReward, len(validator.HistoricalRewards)),
+			RewardStarts:      make(map[string]uint64, len(validator.RewardStarts)),
+		}
+		for delegator, amount := range validator.Delegators {
+			v.Delegators[delegator] = amount
+		}
+		for period, historical := range validator.HistoricalRewards {
+			v.HistoricalRewards[period] = &HistoricalReward{
+				Ratio:          new(big.Int).Set(historical.Ratio),
+				ReferenceCount: historical.ReferenceCount,
+			}
+		}
+		for delegator, period := range validator.RewardStarts {
+			v.RewardStarts[delegator] = period
+		}
+		c.Validators[id] = v
+	}
+	for _, entry := range s.Unbondings {
+		e := *entry
+		c.Unbondings = append(c.Unbondings, &e)
+	}
+	for _, entry := range s.Redelegations {
+		e := *entry
+		c.Redelegations = append(c.Redelegations, &e)
+	}
+	for delegator, amount := range s.RewardBalances {
+		c.RewardBalances[delegator] = amount
+	}
+	c.OutstandingRewards = s.OutstandingRewards
+	if s.consensusKeys != nil {
+		c.consensusKeys = make(map[string]string, len(s.consensusKeys))
+		for key, id := range s.consensusKeys {
+			c.consensusKeys[key] = id
+		}
 	}
+	return c
 }
 
-// Stake allows a user to delegate tokens to a validator.
+// Stake allows a user to delegate tokens to a registered validator.
 func (s *StakingSystem) Stake(delegator string, validatorID string, amount int64) error {
 	if amount <= 0 {
 		return errors.New("stake amount must be positive")
@@ -35,26 +112,20 @@ func (s *StakingSystem) Stake(delegator string, validatorID string, amount int64
 
 	validator, exists := s.Validators[validatorID]
 	if !exists {
-		if len(s.Validators) >= s.MaxValidators {
-			return errors.New("maximum number of validators reached")
-		}
-
-		// Register a new validator
-		validator = &Validator{
-			ID:           validatorID,
-			StakedAmount: 0,
-			Delegators:   make(map[string]int64),
-		}
-		s.Validators[validatorID] = validator
+		return fmt.Errorf("validator %s is not registered", validatorID)
 	}
 
 	// Add stake
+	s.beforeDelegationChange(validator, delegator)
 	validator.StakedAmount += amount
 	validator.Delegators[delegator] += amount
+	validator.initializeDelegation(delegator)
 	return nil
 }
 
-// Unstake allows a user to withdraw their stake from a validator.
+// Unstake removes stake from a validator without releasing it; see
+// Undelegate for withdrawals through the unbonding queue. A validator may
+// withdraw its whole self-bond but not leave less than MinSelfBond of it.
 func (s *StakingSystem) Unstake(delegator string, validatorID string, amount int64) error {
 	validator, exists := s.Validators[validatorID]
 	if !exists {
@@ -65,8 +136,12 @@ func (s *StakingSystem) Unstake(delegator string, validatorID string, amount int
 	if !exists || stakedAmount < amount {
 		return errors.New("not enough stake to withdraw")
 	}
+	if remaining := stakedAmount - amount; delegator == validatorID && remaining > 0 && remaining < s.Params.MinSelfBond {
+		return fmt.Errorf("self-bond can't fall below %d", s.Params.MinSelfBond)
+	}
 
 	// Reduce stake
+	s.beforeDelegationChange(validator, delegator)
 	validator.StakedAmount -= amount
 	validator.Delegators[delegator] -= amount
 
@@ -74,54 +149,35 @@ func (s *StakingSystem) Unstake(delegator string, validatorID string, amount int
 	if validator.Delegators[delegator] == 0 {
 		delete(validator.Delegators, delegator)
 	}
-
-	// Remove validator if their total stake becomes zero
-	if validator.StakedAmount == 0 {
-		delete(s.Validators, validatorID)
-	}
+	validator.initializeDelegation(delegator)
 
 	return nil
 }
 
-// GetTopValidators returns the top N validators by stake.
+// GetTopValidators returns the validators eligible for the active set, up to
+// MaxValidators of them: not jailed, with at least MinSelfBond of their own
+// stake and MinValidatorStake in total. They are ordered by stake, highest
+// first, with ties broken by ascending ID so every node elects the same set.
 func (s *StakingSystem) GetTopValidators() []*Validator {
 	var validators []*Validator
 	for _, v := range s.Validators {
+		if v.Jailed || v.SelfBond() < s.Params.MinSelfBond || v.StakedAmount < s.Params.MinValidatorStake {
+			continue
+		}
 		validators = append(validators, v)
 	}
 
-	// Sort validators by staked amount in descending order
 	sort.Slice(validators, func(i, j int) bool {
-		return validators[i].StakedAmount > validators[j].StakedAmount
-	})
-
-	return validators[:s.MaxValidators]
-}
-
-// DistributeRewards distributes rewards to validators and their delegators.
-func (s *StakingSystem) DistributeRewards(totalReward int64) {
-	for _, validator := range s.Validators {
-		// Calculate validator's share based on their stake
-		reward := totalReward * validator.StakedAmount / s.TotalStake()
-
-		// Split the reward: 25% burn, 25% research fund, 50% validator and delegators
-		burn := reward / 4
-		researchFund := reward / 4
-		validatorShare := reward / 2
-
-		// Validator takes their share
-		validatorReward := validatorShare / 2
-		validator.StakedAmount += validatorReward
-
-		// Distribute the remaining validator share to delegators proportionally
-		delegatorReward := validatorShare / 2
-		for delegator, stake := range validator.Delegators {
-			share := delegatorReward * stake / validator.StakedAmount
-			validator.Delegators[delegator] += share
+		if validators[i].StakedAmount != validators[j].StakedAmount {
+			return validators[i].StakedAmount > validators[j].StakedAmount
 		}
+		return validators[i].ID < validators[j].ID
+	})
 
-		fmt.Printf("Validator %s received %d reward (Burned: %d, Research Fund: %d)\n", validator.ID, validatorReward, burn, researchFund)
+	if int64(len(validators)) > s.Params.MaxValidators {
+		validators = validators[:s.Params.MaxValidators]
 	}
+	return validators
 }
 
 // TotalStake calculates the total stake in the system.
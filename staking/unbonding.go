@@ -0,0 +1,74 @@
+package staking
+
+import "sort"
+
+// UnbondingEntry is stake withdrawn from a validator that stays locked, and
+// slashable, until CompletionHeight.
+type UnbondingEntry struct {
+	Delegator        string
+	ValidatorID      string
+	Amount           int64
+	CreationHeight   int64 // Height of the block that started the unbonding
+	CompletionHeight int64 // Height from which the tokens are released
+}
+
+// Undelegate withdraws stake from a validator into the unbonding queue. The
+// tokens are released by CompleteUnbondings once the unbonding period ends.
+func (s *StakingSystem) Undelegate(delegator string, validatorID string, amount int64, height int64) error {
+	if err := s.Unstake(delegator, validatorID, amount); err != nil {
+		return err
+	}
+
+	s.Unbondings = append(s.Unbondings, &UnbondingEntry{
+		Delegator:        delegator,
+		ValidatorID:      validatorID,
+		Amount:           amount,
+		CreationHeight:   height,
+		CompletionHeight: height + s.Params.UnbondingPeriod,
+	})
+	return nil
+}
+
+// CompleteUnbondings removes the entries that have matured at height and
+// returns them in the order they were queued, so the caller can pay them out.
+func (s *StakingSystem) CompleteUnbondings(height int64) []*UnbondingEntry {
+	var matured, pending []*UnbondingEntry
+	for _, entry := range s.Unbondings {
+		if entry.CompletionHeight <= height {
+			matured = append(matured, entry)
+		} else {
+			pending = append(pending, entry)
+		}
+	}
+	s.Unbondings = pending
+	return matured
+}
+
+// PendingUnbondings returns a delegator's unbonding entries, soonest first.
+func (s *StakingSystem) PendingUnbondings(delegator string) []UnbondingEntry {
+	var entries []UnbondingEntry
+	for _, entry := range s.Unbondings {
+		if entry.Delegator == delegator {
+			entries = append(entries, *entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CompletionHeight < entries[j].CompletionHeight
+	})
+	return entries
+}
+
+// slashUnbondings burns a fraction of the stake unbonding from a validator
+// since the infraction height and returns the amount burned.
+func (s *StakingSystem) slashUnbondings(validatorID string, infractionHeight int64, fraction int64) int64 {
+	var burned int64
+	for _, entry := range s.Unbondings {
+		if entry.ValidatorID != validatorID || entry.CreationHeight < infractionHeight {
+			continue
+		}
+		cut := entry.Amount * fraction / BasisPoints
+		entry.Amount -= cut
+		burned += cut
+	}
+	return burned
+}
@@ -0,0 +1,96 @@
+package staking
+
+import "testing"
+
+// newTestUnbondings queues three undelegations from "val" by "delegator",
+// started out of completion order, with an unbonding period of 10 blocks.
+func newTestUnbondings(t *testing.T) *StakingSystem {
+	t.Helper()
+	s := NewStakingSystem()
+	s.Params.UnbondingPeriod = 10
+	newTestValidator(t, s, "val", 10000)
+	if err := s.Stake("delegator", "val", 1000); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range []struct{ amount, height int64 }{{100, 1}, {200, 5}, {300, 3}} {
+		if err := s.Undelegate("delegator", "val", entry.amount, entry.height); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestUnbondingQueueMaturity(t *testing.T) {
+	for _, tc := range []struct {
+		height  int64
+		matured []int64 // Amounts released, in queue order
+		pending int
+	}{
+		{10, nil, 3},
+		{11, []int64{100}, 2},
+		{13, []int64{100, 300}, 1},
+		{15, []int64{100, 200, 300}, 0},
+	} {
+		s := newTestUnbondings(t)
+		var matured []int64
+		for _, entry := range s.CompleteUnbondings(tc.height) {
+			matured = append(matured, entry.Amount)
+		}
+		if len(matured) != len(tc.matured) {
+			t.Errorf("height %d: released %v, want %v", tc.height, matured, tc.matured)
+			continue
+		}
+		for i := range matured {
+			if matured[i] != tc.matured[i] {
+				t.Errorf("height %d: released %v, want %v", tc.height, matured, tc.matured)
+				break
+			}
+		}
+		if pending := len(s.PendingUnbondings("delegator")); pending != tc.pending {
+			t.Errorf("height %d: %d entries pending, want %d", tc.height, pending, tc.pending)
+		}
+		// Released stake never returns to the validator
+		if got := s.Validators["val"].Delegators["delegator"]; got != 400 {
+			t.Errorf("height %d: delegation is %d, want 400", tc.height, got)
+		}
+	}
+}
+
+func TestPendingUnbondingsSoonestFirst(t *testing.T) {
+	s := newTestUnbondings(t)
+	var completions []int64
+	for _, entry := range s.PendingUnbondings("delegator") {
+		completions = append(completions, entry.CompletionHeight)
+	}
+	if len(completions) != 3 || completions[0] != 11 || completions[1] != 13 || completions[2] != 15 {
+		t.Errorf("pending unbondings complete at %v, want [11 13 15]", completions)
+	}
+}
+
+func TestSlashReachesUnbondingsSinceTheInfraction(t *testing.T) {
+	for _, tc := range []struct {
+		infraction int64
+		remaining  []int64 // Unbonding amounts left after a 10% slash, in queue order
+		burned     int64
+	}{
+		{0, []int64{90, 180, 270}, 1100},
+		{3, []int64{100, 180, 270}, 1090},
+		{4, []int64{100, 180, 300}, 1060},
+		{6, []int64{100, 200, 300}, 1040},
+	} {
+		s := newTestUnbondings(t)
+		// 10% of the 10400 still bonded is burned whatever the infraction height
+		burned, err := s.Slash("val", tc.infraction, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if burned != tc.burned {
+			t.Errorf("infraction at %d: burned %d, want %d", tc.infraction, burned, tc.burned)
+		}
+		for i, entry := range s.Unbondings {
+			if entry.Amount != tc.remaining[i] {
+				t.Errorf("infraction at %d: unbonding %d holds %d, want %d", tc.infraction, i, entry.Amount, tc.remaining[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package state
+
+import (
+	"errors"
+	"math/big"
+	"matrix-blockchain/staking"
+)
+
+// EmissionSchedule mints AnnualRate of the supply cap per year, spread over
+// BlocksPerYear blocks, for as long as the supply stays under the cap.
+type EmissionSchedule struct {
+	SupplyCap     int64 `json:"supply_cap"`      // Largest total supply emission mints up to
+	AnnualRate    int64 `json:"annual_rate"`     // Share of the supply cap minted per year, in basis points
+	BlocksPerYear int64 `json:"blocks_per_year"` // Blocks the annual emission is spread over
+}
+
+// DefaultEmissionSchedule returns the emission used when genesis omits it:
+// 0.5% of a 500,000,000 cap per year at one block every five seconds.
+func DefaultEmissionSchedule() EmissionSchedule {
+	return EmissionSchedule{
+		SupplyCap:     500000000,
+		AnnualRate:    50,
+		BlocksPerYear: 6307200,
+	}
+}
+
+// Validate checks the emission settings.
+func (e EmissionSchedule) Validate() error {
+	if e.SupplyCap < 0 {
+		return errors.New("supply cap cannot be negative")
+	}
+	if e.AnnualRate < 0 || e.AnnualRate > staking.BasisPoints {
+		return errors.New("annual emission rate out of range")
+	}
+	if e.BlocksPerYear <= 0 {
+		return errors.New("blocks per year must be positive")
+	}
+	return nil
+}
+
+// scheduled returns the tokens scheduled for emission from genesis up to and
+// including height. Spreading the running total, rather than rounding each
+// block's reward, keeps fractions of a token from being lost.
+func (e EmissionSchedule) scheduled(height int64) int64 {
+	total := new(big.Int).Mul(big.NewInt(e.SupplyCap), big.NewInt(e.AnnualRate))
+	total.Mul(total, big.NewInt(height))
+	total.Quo(total, big.NewInt(staking.BasisPoints*e.BlocksPerYear))
+	return total.Int64()
+}
+
+// BlockReward returns the tokens to mint for the block at height given the
+// current total supply.
+func (e EmissionSchedule) BlockReward(height int64, totalSupply int64) int64 {
+	if e.BlocksPerYear <= 0 || height <= 0 {
+		return 0
+	}
+	reward := e.scheduled(height) - e.scheduled(height-1)
+	if room := e.SupplyCap - totalSupply; reward > room {
+		reward = room
+	}
+	if reward < 0 {
+		return 0
+	}
+	return reward
+}
+
+// applyEmission mints the block reward and splits it by the reward split
+// parameters: the validator share is distributed to the active set in
+// proportion to stake, the burn share is destroyed and the research share goes
+// to the treasury. Without bonded validators to receive it the validator share
+// isn't minted.
+func (s *State) applyEmission() {
+	minted := s.Emission.BlockReward(s.Height, s.Supply.Total)
+	if minted == 0 {
+		return
+	}
+	reward := s.Staking.Params.SplitReward(minted)
+
+	ids := make([]string, len(s.ActiveSet))
+	for i, member := range s.ActiveSet {
+		ids[i] = member.ID
+	}
+	if s.Staking.DistributeRewards(reward.ValidatorAmount, ids) == 0 {
+		minted -= reward.ValidatorAmount
+	}
+
+	s.Supply.Total += minted
+	s.Supply.Minted += minted
+	s.burn(reward.BurnAmount)
+	s.Treasury += reward.ResearchFundAmount
+}
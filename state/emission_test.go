@@ -0,0 +1,116 @@
+package state
+
+import (
+	"encoding/hex"
+	"matrix-blockchain/staking"
+	"matrix-blockchain/utils"
+	"testing"
+)
+
+// newTestState creates a state with funded accounts and validators bonded
+// with the given self-bonds.
+func newTestState(t *testing.T, emission EmissionSchedule, selfBonds ...int64) *State {
+	t.Helper()
+	genesis := &Genesis{Params: staking.DefaultParams(), Emission: emission}
+	for _, selfBond := range selfBonds {
+		_, pub := utils.GenerateKeys()
+		genesis.Validators = append(genesis.Validators, GenesisValidator{
+			Address:     utils.PublicKeyToAddress(pub),
+			PubKey:      hex.EncodeToString(utils.EncodePublicKey(pub)),
+			Power:       selfBond,
+			Description: staking.Description{Moniker: "validator"},
+		})
+	}
+	s, err := NewStateFromGenesis(genesis, staking.NewStakingSystem())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestBlockRewardsAddUpToTheAnnualEmission(t *testing.T) {
+	emission := EmissionSchedule{SupplyCap: 1000003, AnnualRate: 777, BlocksPerYear: 997}
+
+	var minted int64
+	for height := int64(1); height <= 3*emission.BlocksPerYear; height++ {
+		minted += emission.BlockReward(height, 0)
+	}
+	// No fraction of a token is lost to per-block rounding
+	if want := 3 * emission.SupplyCap * emission.AnnualRate / staking.BasisPoints; minted != want {
+		t.Errorf("minted %d over three years, want %d", minted, want)
+	}
+}
+
+func TestBlockRewardStopsAtTheSupplyCap(t *testing.T) {
+	emission := EmissionSchedule{SupplyCap: 1000, AnnualRate: staking.BasisPoints, BlocksPerYear: 10}
+
+	if got := emission.BlockReward(1, 950); got != 50 {
+		t.Errorf("reward near the cap = %d, want 50", got)
+	}
+	if got := emission.BlockReward(1, 1000); got != 0 {
+		t.Errorf("reward at the cap = %d, want 0", got)
+	}
+	if got := emission.BlockReward(1, 1200); got != 0 {
+		t.Errorf("reward above the cap = %d, want 0", got)
+	}
+}
+
+func TestEmissionIsSplitAndAccountedFor(t *testing.T) {
+	emission := EmissionSchedule{SupplyCap: 1 << 40, AnnualRate: 1234, BlocksPerYear: 101}
+	s := newTestState(t, emission, 10000, 23456, 70001)
+
+	var minted, burned, treasury int64
+	for height := int64(1); height <= 300; height++ {
+		s.Height = height
+		reward := emission.BlockReward(height, s.Supply.Total)
+		split := s.Staking.Params.SplitReward(reward)
+
+		s.applyEmission()
+		minted += reward
+		burned += split.BurnAmount
+		treasury += split.ResearchFundAmount
+
+		if err := s.CheckSupply(); err != nil {
+			t.Fatalf("height %d: %v", height, err)
+		}
+	}
+
+	if s.Supply.Minted != minted || s.Supply.Burned != burned || s.Treasury != treasury {
+		t.Errorf("minted %d, burned %d, treasury %d; want %d, %d, %d",
+			s.Supply.Minted, s.Supply.Burned, s.Treasury, minted, burned, treasury)
+	}
+	if validators := minted - burned - treasury; s.Staking.OutstandingRewards != validators {
+		t.Errorf("validators were allocated %d, want %d", s.Staking.OutstandingRewards, validators)
+	}
+}
+
+func TestEmissionWithoutValidatorsMintsNoValidatorShare(t *testing.T) {
+	emission := EmissionSchedule{SupplyCap: 1 << 40, AnnualRate: 1000, BlocksPerYear: 100}
+	s := newTestState(t, emission)
+	s.Height = 1
+	reward := emission.BlockReward(1, s.Supply.Total)
+	split := s.Staking.Params.SplitReward(reward)
+
+	s.applyEmission()
+	if want := split.BurnAmount + split.ResearchFundAmount; s.Supply.Minted != want {
+		t.Errorf("minted %d without validators, want %d", s.Supply.Minted, want)
+	}
+	if err := s.CheckSupply(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGenesisMustLeaveRoomForEmission(t *testing.T) {
+	genesis := &Genesis{
+		Params:   staking.DefaultParams(),
+		Emission: EmissionSchedule{SupplyCap: 1000, AnnualRate: 100, BlocksPerYear: 10},
+		Accounts: []GenesisAccount{{Address: "MRX-Funded", Balance: 1000}},
+	}
+	if err := genesis.Validate(); err == nil {
+		t.Error("genesis funding the whole supply cap accepted")
+	}
+	genesis.Accounts[0].Balance = 999
+	if err := genesis.Validate(); err != nil {
+		t.Errorf("genesis below the cap rejected: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package state
+
+import "sort"
+
+// ActiveValidator is a member of the active set with the voting power it
+// was elected with.
+type ActiveValidator struct {
+	ID     string
+	PubKey []byte
+	Power  int64
+}
+
+// Epoch returns the epoch the given height belongs to. Epoch 0 starts at
+// genesis and each epoch lasts EpochLength blocks.
+func (s *State) Epoch(height int64) int64 {
+	return height / s.Staking.Params.EpochLength
+}
+
+// IsEpochBoundary reports whether the block at height ends an epoch, so the
+// active set is recomputed after it.
+func (s *State) IsEpochBoundary(height int64) bool {
+	return height%s.Staking.Params.EpochLength == 0
+}
+
+// updateActiveSet rotates the active set after a block. At epoch boundaries
+// the set is elected afresh from the staking state and takes effect from the
+// next block. Within an epoch memberships and powers are fixed, except that
+// jailed and removed validators drop out immediately.
+func (s *State) updateActiveSet() {
+	s.LastActiveSet = s.ActiveSet
+	if s.IsEpochBoundary(s.Height) {
+		s.ActiveSet = s.electValidators()
+		return
+	}
+
+	var active []ActiveValidator
+	for _, member := range s.ActiveSet {
+		validator, exists := s.Staking.Validators[member.ID]
+		if !exists || validator.Jailed {
+			continue
+		}
+		active = append(active, member)
+	}
+	s.ActiveSet = active
+}
+
+// electValidators elects the active set from the staking state's top
+// validators. The result is ordered by ID.
+func (s *State) electValidators() []ActiveValidator {
+	var elected []ActiveValidator
+	for _, v := range s.Staking.GetTopValidators() {
+		elected = append(elected, ActiveValidator{ID: v.ID, PubKey: v.ConsensusPubKey, Power: v.StakedAmount})
+	}
+
+	sort.Slice(elected, func(i, j int) bool {
+		return elected[i].ID < elected[j].ID
+	})
+	return elected
+}
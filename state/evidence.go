@@ -0,0 +1,53 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"matrix-blockchain/blockchain"
+	"matrix-blockchain/staking"
+	"matrix-blockchain/transaction"
+)
+
+// ApplyEvidence verifies evidence of validator misbehaviour against the
+// validator's registered consensus key, slashes the validator and jails it
+// permanently. Each infraction is punished at most once.
+func (s *State) ApplyEvidence(evidence *transaction.Evidence) error {
+	if err := evidence.ValidateBasic(); err != nil {
+		return err
+	}
+	if evidence.Height >= s.Height {
+		return fmt.Errorf("evidence for height %d is not in the past", evidence.Height)
+	}
+	if s.Height-evidence.Height > s.Staking.Params.MaxEvidenceAge {
+		return fmt.Errorf("evidence for height %d has expired", evidence.Height)
+	}
+
+	ev, err := blockchain.DecodeDuplicateVoteEvidence(*evidence)
+	if err != nil {
+		return err
+	}
+	validator, exists := s.Staking.Validators[evidence.ValidatorID]
+	if !exists || len(validator.ConsensusPubKey) == 0 {
+		return errors.New("evidence against an unknown validator")
+	}
+	if err := ev.Verify(validator.ConsensusPubKey); err != nil {
+		return err
+	}
+
+	key := infractionKey(evidence.ValidatorID, evidence.Height)
+	if s.Slashed[key] {
+		return errors.New("infraction already punished")
+	}
+	burned, err := s.Staking.Slash(evidence.ValidatorID, evidence.Height, s.Staking.Params.SlashFractionDoubleSign)
+	if err != nil {
+		return err
+	}
+	s.burn(burned)
+	s.Slashed[key] = true
+	return s.Staking.Jail(evidence.ValidatorID, staking.JailedForever)
+}
+
+// infractionKey identifies a validator's misbehaviour at a height.
+func infractionKey(validatorID string, height int64) string {
+	return fmt.Sprintf("%s/%d", validatorID, height)
+}
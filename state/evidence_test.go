@@ -0,0 +1,112 @@
+package state
+
+import (
+	"crypto/ecdsa"
+	"matrix-blockchain/blockchain"
+	"matrix-blockchain/staking"
+	"matrix-blockchain/transaction"
+	"matrix-blockchain/utils"
+	"testing"
+)
+
+// registerTestValidator registers a validator with a self-bond and a
+// delegation, and returns its address and consensus key.
+func registerTestValidator(t *testing.T, s *State, selfBond, delegated int64) (string, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, pub := utils.GenerateKeys()
+	id := utils.PublicKeyToAddress(pub)
+	if err := s.Staking.RegisterValidator(id, selfBond, utils.EncodePublicKey(pub), staking.Commission{}, staking.Description{Moniker: "validator"}, s.Height); err != nil {
+		t.Fatal(err)
+	}
+	if delegated > 0 {
+		if err := s.Staking.Stake("MRX-Delegator", id, delegated); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return id, key
+}
+
+// newTestEvidence returns evidence of a validator precommitting two blocks at
+// a height, signed with key.
+func newTestEvidence(t *testing.T, id string, key *ecdsa.PrivateKey, height int64, hashA, hashB string) *transaction.Evidence {
+	t.Helper()
+	var votes []*blockchain.Vote
+	for _, hash := range []string{hashA, hashB} {
+		vote := &blockchain.Vote{Type: blockchain.Precommit, Height: height, BlockHash: hash, ValidatorID: id}
+		if err := vote.Sign(key); err != nil {
+			t.Fatal(err)
+		}
+		votes = append(votes, vote)
+	}
+	evidence, err := blockchain.NewDuplicateVoteEvidence(votes[0], votes[1]).ToEvidence()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &evidence
+}
+
+func TestDoubleSignEvidence(t *testing.T) {
+	outsider, _ := utils.GenerateKeys()
+	maxAge := staking.DefaultParams().MaxEvidenceAge
+	for _, tc := range []struct {
+		name     string
+		age      int64 // Blocks between the double sign and the evidence
+		hashB    string
+		wrongKey bool
+		valid    bool
+	}{
+		{"slashed", 10, "B'", false, true},
+		{"oldest punishable", maxAge, "B'", false, true},
+		{"expired", maxAge + 1, "B'", false, false},
+		{"not in the past", 0, "B'", false, false},
+		{"same block", 10, "B", false, false},
+		{"other key", 10, "B'", true, false},
+	} {
+		s := newTestState(t, DefaultEmissionSchedule(), 10000)
+		s.Height = maxAge + 100
+		height := s.Height - tc.age
+		id, key := registerTestValidator(t, s, 20000, 20000)
+		if tc.wrongKey {
+			key = outsider
+		}
+		evidence := newTestEvidence(t, id, key, height, "B", tc.hashB)
+		burned := s.Supply.Burned
+
+		err := s.ApplyEvidence(evidence)
+		if !tc.valid {
+			if err == nil {
+				t.Errorf("%s: evidence accepted", tc.name)
+			}
+			if s.Staking.Validators[id].Jailed || s.Supply.Burned != burned {
+				t.Errorf("%s: rejected evidence punished the validator", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		// 5% of the self-bond and of the delegation is burned
+		validator := s.Staking.Validators[id]
+		if validator.StakedAmount != 38000 || validator.SelfBond() != 19000 || validator.Delegators["MRX-Delegator"] != 19000 {
+			t.Errorf("%s: stake after slashing is %d with a self-bond of %d, want 38000 and 19000", tc.name, validator.StakedAmount, validator.SelfBond())
+		}
+		if s.Supply.Burned-burned != 2000 {
+			t.Errorf("%s: burned %d, want 2000", tc.name, s.Supply.Burned-burned)
+		}
+
+		// The validator is tombstoned and the infraction is punished once
+		if !validator.Jailed || validator.JailedUntil != staking.JailedForever {
+			t.Errorf("%s: validator is not jailed forever", tc.name)
+		}
+		if err := s.Staking.Unjail(id, staking.JailedForever-1); err == nil {
+			t.Errorf("%s: tombstoned validator unjailed", tc.name)
+		}
+		if err := s.ApplyEvidence(evidence); err == nil {
+			t.Errorf("%s: the same infraction was punished twice", tc.name)
+		}
+		if err := s.ApplyEvidence(newTestEvidence(t, id, key, height, "B", "B''")); err == nil {
+			t.Errorf("%s: other evidence of the same infraction was punished again", tc.name)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package state
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"matrix-blockchain/staking"
+	"matrix-blockchain/utils"
+	"os"
+)
+
+// GenesisAccount is an account funded at genesis.
+type GenesisAccount struct {
+	Address string `json:"address"`
+	Balance int64  `json:"balance"`
+}
+
+// GenesisValidator is a validator in the initial validator set.
+type GenesisValidator struct {
+	Address     string              `json:"address"`
+	PubKey      string              `json:"pub_key"` // Hex-encoded public key that signs consensus votes
+	Power       int64               `json:"power"`   // Initial self-bond and voting power
+	Commission  staking.Commission  `json:"commission"`
+	Description staking.Description `json:"description"`
+}
+
+// Genesis describes the initial chain state.
+type Genesis struct {
+	GenesisTime     int64              `json:"genesis_time"` // Timestamp of the genesis block
+	Accounts        []GenesisAccount   `json:"accounts"`
+	VestingAccounts []VestingAccount   `json:"vesting_accounts"`
+	Validators      []GenesisValidator `json:"validators"`
+	Params          staking.Params     `json:"params"`   // Chain parameters, defaulted when omitted
+	Emission        EmissionSchedule   `json:"emission"` // Block reward schedule, defaulted when omitted
+}
+
+// LoadGenesis reads the genesis description from a JSON file.
+func LoadGenesis(path string) (*Genesis, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read genesis file: %v", err)
+	}
+
+	genesis := Genesis{Params: staking.DefaultParams(), Emission: DefaultEmissionSchedule()}
+	if err := json.Unmarshal(data, &genesis); err != nil {
+		return nil, fmt.Errorf("failed to parse genesis file: %v", err)
+	}
+	if err := genesis.Validate(); err != nil {
+		return nil, err
+	}
+	return &genesis, nil
+}
+
+// Validate checks the genesis accounts, vesting schedules and validators.
+func (g *Genesis) Validate() error {
+	if err := g.Params.Validate(); err != nil {
+		return fmt.Errorf("invalid genesis params: %v", err)
+	}
+	if err := g.Emission.Validate(); err != nil {
+		return fmt.Errorf("invalid genesis emission: %v", err)
+	}
+
+	for _, account := range g.Accounts {
+		if !utils.ValidateAddress(account.Address) {
+			return fmt.Errorf("invalid genesis account address: %s", account.Address)
+		}
+		if account.Balance < 0 {
+			return fmt.Errorf("negative genesis balance for %s", account.Address)
+		}
+	}
+
+	vesting := make(map[string]bool)
+	for _, account := range g.VestingAccounts {
+		if err := account.Validate(); err != nil {
+			return err
+		}
+		if vesting[account.Address] {
+			return fmt.Errorf("duplicate vesting account: %s", account.Address)
+		}
+		vesting[account.Address] = true
+	}
+
+	addresses := make(map[string]bool)
+	consensusKeys := make(map[string]bool)
+	for _, validator := range g.Validators {
+		if !utils.ValidateAddress(validator.Address) {
+			return fmt.Errorf("invalid genesis validator address: %s", validator.Address)
+		}
+		if addresses[validator.Address] {
+			return fmt.Errorf("duplicate genesis validator: %s", validator.Address)
+		}
+		addresses[validator.Address] = true
+		if validator.Power < g.Params.MinSelfBond {
+			return fmt.Errorf("genesis validator %s must have a power of at least %d", validator.Address, g.Params.MinSelfBond)
+		}
+		if err := validator.Commission.Validate(); err != nil {
+			return fmt.Errorf("genesis validator %s: %v", validator.Address, err)
+		}
+		if err := validator.Description.Validate(); err != nil {
+			return fmt.Errorf("genesis validator %s: %v", validator.Address, err)
+		}
+		pubKey, err := hex.DecodeString(validator.PubKey)
+		if err != nil {
+			return fmt.Errorf("invalid public key for genesis validator %s: %v", validator.Address, err)
+		}
+		if _, err := utils.DecodePublicKey(pubKey); err != nil {
+			return fmt.Errorf("invalid public key for genesis validator %s: %v", validator.Address, err)
+		}
+		if consensusKeys[string(pubKey)] {
+			return fmt.Errorf("genesis validator %s reuses another validator's public key", validator.Address)
+		}
+		consensusKeys[string(pubKey)] = true
+	}
+
+	if supply := g.Supply(); g.Emission.AnnualRate > 0 && supply >= g.Emission.SupplyCap {
+		return fmt.Errorf("genesis supply %d leaves no room for emission under the cap of %d", supply, g.Emission.SupplyCap)
+	}
+	return nil
+}
+
+// Supply returns the tokens created at genesis.
+func (g *Genesis) Supply() int64 {
+	var supply int64
+	for _, account := range g.Accounts {
+		supply += account.Balance
+	}
+	for _, account := range g.VestingAccounts {
+		supply += account.OriginalVesting
+	}
+	for _, validator := range g.Validators {
+		supply += validator.Power
+	}
+	return supply
+}
+
+// NewStateFromGenesis creates the chain state at height 0.
+func NewStateFromGenesis(genesis *Genesis, stakingSystem *staking.StakingSystem) (*State, error) {
+	if err := genesis.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := NewState(stakingSystem)
+	s.Staking.Params = genesis.Params
+	s.Emission = genesis.Emission
+	for _, account := range genesis.Accounts {
+		s.credit(account.Address, account.Balance)
+		s.Supply.Total += account.Balance
+	}
+	for _, account := range genesis.VestingAccounts {
+		vesting := account
+		s.Vesting[account.Address] = &vesting
+		s.credit(account.Address, account.OriginalVesting)
+		s.Supply.Total += account.OriginalVesting
+	}
+	for _, validator := range genesis.Validators {
+		pubKey, _ := hex.DecodeString(validator.PubKey)
+		if err := s.Staking.RegisterValidator(validator.Address, validator.Power, pubKey, validator.Commission, validator.Description, 0); err != nil {
+			return nil, fmt.Errorf("failed to register genesis validator %s: %v", validator.Address, err)
+		}
+		s.Supply.Total += validator.Power
+	}
+	s.Supply.Genesis = s.Supply.Total
+	s.ActiveSet = s.electValidators()
+	return s, nil
+}
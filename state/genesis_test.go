@@ -0,0 +1,35 @@
+package state
+
+import (
+	"encoding/hex"
+	"matrix-blockchain/staking"
+	"matrix-blockchain/utils"
+	"testing"
+)
+
+func TestGenesisRejectsSharedValidatorKeys(t *testing.T) {
+	_, pub := utils.GenerateKeys()
+	key := hex.EncodeToString(utils.EncodePublicKey(pub))
+	_, other := utils.GenerateKeys()
+	validator := func(address, pubKey string) GenesisValidator {
+		return GenesisValidator{Address: address, PubKey: pubKey, Power: 10000, Description: staking.Description{Moniker: "validator"}}
+	}
+
+	for name, tc := range map[string]struct {
+		validators []GenesisValidator
+		valid      bool
+	}{
+		"distinct keys":  {[]GenesisValidator{validator("MRX-a", key), validator("MRX-b", hex.EncodeToString(utils.EncodePublicKey(other)))}, true},
+		"shared key":     {[]GenesisValidator{validator("MRX-a", key), validator("MRX-b", key)}, false},
+		"same validator": {[]GenesisValidator{validator("MRX-a", key), validator("MRX-a", hex.EncodeToString(utils.EncodePublicKey(other)))}, false},
+	} {
+		genesis := &Genesis{Params: staking.DefaultParams(), Emission: DefaultEmissionSchedule(), Validators: tc.validators}
+		err := genesis.Validate()
+		if tc.valid && err != nil {
+			t.Errorf("%s: rejected: %v", name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"matrix-blockchain/staking"
+	"matrix-blockchain/transaction"
+	"sort"
+)
+
+// Proposal statuses.
+const (
+	ProposalVoting   = "voting"
+	ProposalPassed   = "passed"
+	ProposalRejected = "rejected"
+	ProposalFailed   = "failed" // Passed but could not be executed
+)
+
+// Proposal is a governance proposal voted on by bonded stake.
+type Proposal struct {
+	ID              uint64
+	Type            string
+	Proposer        string
+	Title           string
+	Description     string
+	Grant           *transaction.TreasuryGrant // Set for treasury grant proposals
+	ParamChanges    map[string]json.RawMessage // Set for parameter change proposals
+	SubmitHeight    int64
+	VotingEndHeight int64 // Last height votes are accepted at; the proposal is tallied after it
+	Status          string
+	Tally           Tally
+}
+
+// Tally is the bonded stake behind each vote option.
+type Tally struct {
+	Yes     int64
+	No      int64
+	Abstain int64
+}
+
+// submitProposal opens a proposal for voting until VotingPeriod blocks after
+// the current height.
+func (s *State) submitProposal(proposer string, p *transaction.SubmitProposalPayload) *Proposal {
+	s.NextProposalID++
+	proposal := &Proposal{
+		ID:              s.NextProposalID,
+		Type:            p.Type,
+		Proposer:        proposer,
+		Title:           p.Title,
+		Description:     p.Description,
+		Grant:           p.Grant,
+		ParamChanges:    p.ParamChanges,
+		SubmitHeight:    s.Height,
+		VotingEndHeight: s.Height + s.Staking.Params.VotingPeriod,
+		Status:          ProposalVoting,
+	}
+	s.Proposals[proposal.ID] = proposal
+	return proposal
+}
+
+// vote records a voter's option on a proposal open for voting, replacing any
+// earlier vote.
+func (s *State) vote(proposalID uint64, voter string, option string) error {
+	proposal, exists := s.Proposals[proposalID]
+	if !exists {
+		return fmt.Errorf("proposal %d does not exist", proposalID)
+	}
+	if proposal.Status != ProposalVoting || s.Height > proposal.VotingEndHeight {
+		return fmt.Errorf("proposal %d is not open for voting", proposalID)
+	}
+
+	if s.Votes[proposalID] == nil {
+		s.Votes[proposalID] = make(map[string]string)
+	}
+	s.Votes[proposalID][voter] = option
+	return nil
+}
+
+// tally weighs a proposal's votes by the voters' bonded stake.
+func (s *State) tally(proposalID uint64) Tally {
+	votes := s.Votes[proposalID]
+	var tally Tally
+	for _, validator := range s.Staking.Validators {
+		for delegator, amount := range validator.Delegators {
+			switch votes[delegator] {
+			case transaction.VoteYes:
+				tally.Yes += amount
+			case transaction.VoteNo:
+				tally.No += amount
+			case transaction.VoteAbstain:
+				tally.Abstain += amount
+			}
+		}
+	}
+	return tally
+}
+
+// passes reports whether a tally reaches the quorum of bonded stake and more
+// than the threshold of yes among yes and no votes.
+func (s *State) passes(tally Tally) bool {
+	params := s.Staking.Params
+	voted := tally.Yes + tally.No + tally.Abstain
+	if voted == 0 || voted*staking.BasisPoints < params.Quorum*s.Staking.TotalStake() {
+		return false
+	}
+	return tally.Yes*staking.BasisPoints > params.Threshold*(tally.Yes+tally.No)
+}
+
+// endProposals tallies the proposals whose voting ended at the current height,
+// in ID order, and executes those that passed.
+func (s *State) endProposals() {
+	var ended []uint64
+	for id, proposal := range s.Proposals {
+		if proposal.Status == ProposalVoting && proposal.VotingEndHeight <= s.Height {
+			ended = append(ended, id)
+		}
+	}
+	sort.Slice(ended, func(i, j int) bool { return ended[i] < ended[j] })
+
+	for _, id := range ended {
+		proposal := s.Proposals[id]
+		proposal.Tally = s.tally(id)
+		delete(s.Votes, id)
+
+		if !s.passes(proposal.Tally) {
+			proposal.Status = ProposalRejected
+			continue
+		}
+		if err := s.executeProposal(proposal); err != nil {
+			proposal.Status = ProposalFailed
+			continue
+		}
+		proposal.Status = ProposalPassed
+	}
+}
+
+// executeProposal applies a proposal that passed.
+func (s *State) executeProposal(proposal *Proposal) error {
+	switch proposal.Type {
+	case transaction.ProposalTreasuryGrant:
+		return s.awardGrant(proposal)
+	case transaction.ProposalParamChange:
+		params, err := applyParamChanges(s.Staking.Params, proposal.ParamChanges)
+		if err != nil {
+			return err
+		}
+		s.Staking.Params = params
+		return nil
+	default:
+		return errors.New("unknown proposal type")
+	}
+}
+
+// applyParamChanges returns the chain parameters with the changes applied,
+// checking that every changed parameter exists and that the result is valid.
+func applyParamChanges(params staking.Params, changes map[string]json.RawMessage) (staking.Params, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return params, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return params, err
+	}
+
+	for name, value := range changes {
+		if _, exists := fields[name]; !exists {
+			return params, fmt.Errorf("unknown parameter: %s", name)
+		}
+		fields[name] = value
+	}
+
+	if data, err = json.Marshal(fields); err != nil {
+		return params, err
+	}
+	var changed staking.Params
+	if err := json.Unmarshal(data, &changed); err != nil {
+		return params, fmt.Errorf("invalid parameter change: %v", err)
+	}
+	if err := changed.Validate(); err != nil {
+		return params, fmt.Errorf("invalid parameters: %v", err)
+	}
+	return changed, nil
+}
@@ -0,0 +1,61 @@
+package state
+
+import (
+	"encoding/json"
+	"matrix-blockchain/staking"
+	"matrix-blockchain/transaction"
+	"testing"
+)
+
+func TestApplyParamChanges(t *testing.T) {
+	params := staking.DefaultParams()
+
+	changed, err := applyParamChanges(params, map[string]json.RawMessage{
+		"validator_share": json.RawMessage("6500"),
+		"burn_share":      json.RawMessage("1000"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.ValidatorShare != 6500 || changed.BurnShare != 1000 || changed.ResearchShare != 2500 {
+		t.Errorf("changed shares are %d/%d/%d, want 6500/1000/2500", changed.ValidatorShare, changed.BurnShare, changed.ResearchShare)
+	}
+	changed.ValidatorShare, changed.BurnShare = params.ValidatorShare, params.BurnShare
+	if changed != params {
+		t.Error("parameters that weren't changed differ")
+	}
+
+	for name, changes := range map[string]map[string]json.RawMessage{
+		"unknown parameter": {"no_such_param": json.RawMessage("1")},
+		"wrong type":        {"quorum": json.RawMessage(`"half"`)},
+		"invalid result":    {"burn_share": json.RawMessage("9000")},
+	} {
+		if _, err := applyParamChanges(params, changes); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+}
+
+func TestParamChangeProposalChangesTheSplit(t *testing.T) {
+	s := newTestState(t, EmissionSchedule{SupplyCap: 1 << 40, AnnualRate: 1000, BlocksPerYear: 100}, 10000)
+	proposal := &Proposal{ID: 1, Type: transaction.ProposalParamChange, ParamChanges: map[string]json.RawMessage{
+		"validator_share": json.RawMessage("9000"),
+		"burn_share":      json.RawMessage("0"),
+		"research_share":  json.RawMessage("1000"),
+	}}
+	if err := s.executeProposal(proposal); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Height = 1
+	s.applyEmission()
+	if s.Supply.Burned != 0 {
+		t.Errorf("burned %d after the burn share was set to 0", s.Supply.Burned)
+	}
+	if want := s.Supply.Minted / 10; s.Treasury != want {
+		t.Errorf("treasury got %d of %d minted, want %d", s.Treasury, s.Supply.Minted, want)
+	}
+	if err := s.CheckSupply(); err != nil {
+		t.Error(err)
+	}
+}
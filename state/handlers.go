@@ -0,0 +1,172 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"matrix-blockchain/staking"
+	"matrix-blockchain/transaction"
+)
+
+// txHandler applies a transaction of a single type. Handlers check all
+// preconditions before mutating state so a failed transaction has no effect.
+type txHandler func(s *State, tx *transaction.Transaction) error
+
+var handlers = map[transaction.TxType]txHandler{
+	transaction.TxTransfer:           handleTransfer,
+	transaction.TxStake:              handleStake,
+	transaction.TxUnstake:            handleUnstake,
+	transaction.TxRedelegate:         handleRedelegate,
+	transaction.TxRegisterValidator:  handleRegisterValidator,
+	transaction.TxEditValidator:      handleEditValidator,
+	transaction.TxGovernanceVote:     handleGovernanceVote,
+	transaction.TxSubmitProposal:     handleSubmitProposal,
+	transaction.TxResearchGrantClaim: handleResearchGrantClaim,
+	transaction.TxUnjail:             handleUnjail,
+	transaction.TxWithdrawRewards:    handleWithdrawRewards,
+}
+
+func handleTransfer(s *State, tx *transaction.Transaction) error {
+	if err := s.debit(tx.From, tx.Amount); err != nil {
+		return err
+	}
+	s.credit(tx.To, tx.Amount)
+	return nil
+}
+
+func handleStake(s *State, tx *transaction.Transaction) error {
+	payload, err := tx.DecodePayload()
+	if err != nil {
+		return err
+	}
+	p := payload.(*transaction.StakePayload)
+
+	if s.SpendableBalance(tx.From) < p.Amount {
+		return fmt.Errorf("insufficient balance to stake %d", p.Amount)
+	}
+	if err := s.Staking.Stake(tx.From, p.ValidatorID, p.Amount); err != nil {
+		return err
+	}
+	return s.debit(tx.From, p.Amount)
+}
+
+func handleUnstake(s *State, tx *transaction.Transaction) error {
+	payload, err := tx.DecodePayload()
+	if err != nil {
+		return err
+	}
+	p := payload.(*transaction.UnstakePayload)
+
+	return s.Staking.Undelegate(tx.From, p.ValidatorID, p.Amount, s.Height)
+}
+
+func handleRedelegate(s *State, tx *transaction.Transaction) error {
+	payload, err := tx.DecodePayload()
+	if err != nil {
+		return err
+	}
+	p := payload.(*transaction.RedelegatePayload)
+
+	return s.Staking.Redelegate(tx.From, p.SrcValidatorID, p.DstValidatorID, p.Amount, s.Height)
+}
+
+func handleRegisterValidator(s *State, tx *transaction.Transaction) error {
+	payload, err := tx.DecodePayload()
+	if err != nil {
+		return err
+	}
+	p := payload.(*transaction.RegisterValidatorPayload)
+
+	if s.SpendableBalance(tx.From) < p.SelfBond {
+		return fmt.Errorf("insufficient balance for self-bond of %d", p.SelfBond)
+	}
+	commission := staking.Commission{Rate: p.CommissionRate, MaxChangeRate: p.MaxCommissionChangeRate}
+	description := staking.Description{Moniker: p.Moniker, Website: p.Website, Contact: p.Contact}
+	if err := s.Staking.RegisterValidator(tx.From, p.SelfBond, p.ConsensusPubKey, commission, description, s.Height); err != nil {
+		return err
+	}
+	return s.debit(tx.From, p.SelfBond)
+}
+
+func handleEditValidator(s *State, tx *transaction.Transaction) error {
+	payload, err := tx.DecodePayload()
+	if err != nil {
+		return err
+	}
+	p := payload.(*transaction.EditValidatorPayload)
+
+	description := staking.Description{Moniker: p.Moniker, Website: p.Website, Contact: p.Contact}
+	return s.Staking.EditValidator(tx.From, description, p.CommissionRate, s.Height)
+}
+
+func handleGovernanceVote(s *State, tx *transaction.Transaction) error {
+	payload, err := tx.DecodePayload()
+	if err != nil {
+		return err
+	}
+	p := payload.(*transaction.GovernanceVotePayload)
+
+	return s.vote(p.ProposalID, tx.From, p.Option)
+}
+
+func handleSubmitProposal(s *State, tx *transaction.Transaction) error {
+	payload, err := tx.DecodePayload()
+	if err != nil {
+		return err
+	}
+	p := payload.(*transaction.SubmitProposalPayload)
+
+	if p.Type == transaction.ProposalParamChange {
+		if _, err := applyParamChanges(s.Staking.Params, p.ParamChanges); err != nil {
+			return err
+		}
+	}
+	s.submitProposal(tx.From, p)
+	return nil
+}
+
+func handleResearchGrantClaim(s *State, tx *transaction.Transaction) error {
+	payload, err := tx.DecodePayload()
+	if err != nil {
+		return err
+	}
+	p := payload.(*transaction.ResearchGrantClaimPayload)
+
+	grant, exists := s.Grants[p.GrantID]
+	if !exists {
+		return fmt.Errorf("grant %s does not exist", p.GrantID)
+	}
+	if grant.Recipient != tx.From {
+		return errors.New("grant can only be claimed by its recipient")
+	}
+	if grant.Claimed {
+		return fmt.Errorf("grant %s has already been claimed", p.GrantID)
+	}
+
+	_, err = s.claimGrant(grant)
+	return err
+}
+
+func handleUnjail(s *State, tx *transaction.Transaction) error {
+	if _, err := tx.DecodePayload(); err != nil {
+		return err
+	}
+
+	if err := s.Staking.Unjail(tx.From, s.Height); err != nil {
+		return err
+	}
+	delete(s.SigningInfos, tx.From)
+	return nil
+}
+
+func handleWithdrawRewards(s *State, tx *transaction.Transaction) error {
+	if _, err := tx.DecodePayload(); err != nil {
+		return err
+	}
+
+	rewards := s.Staking.WithdrawRewards(tx.From)
+	if rewards == 0 {
+		return errors.New("no rewards to withdraw")
+	}
+	s.credit(tx.From, rewards)
+	return nil
+}
@@ -0,0 +1,103 @@
+package state
+
+import (
+	"matrix-blockchain/staking"
+	"matrix-blockchain/transaction"
+	"matrix-blockchain/utils"
+	"testing"
+)
+
+func TestTransactionHandlers(t *testing.T) {
+	_, consensusPub := utils.GenerateKeys()
+	for _, tc := range []struct {
+		name    string
+		txType  transaction.TxType
+		payload transaction.Payload // nil for a transfer of 100
+		staked  int64               // Stake the sender has on MRX-Val beforehand
+		debit   int64               // Balance taken besides the fee, -1 if the transaction fails
+		check   func(s *State, sender string) bool
+	}{
+		{"transfer", transaction.TxTransfer, nil, 0, 100, func(s *State, sender string) bool {
+			return s.Balances["MRX-Receiver"] == 100
+		}},
+		{"stake", transaction.TxStake, &transaction.StakePayload{ValidatorID: "MRX-Val", Amount: 1000}, 0, 1000, func(s *State, sender string) bool {
+			return s.Staking.Validators["MRX-Val"].Delegators[sender] == 1000
+		}},
+		{"stake more than the balance", transaction.TxStake, &transaction.StakePayload{ValidatorID: "MRX-Val", Amount: 50000}, 0, -1, nil},
+		{"stake on an unknown validator", transaction.TxStake, &transaction.StakePayload{ValidatorID: "MRX-Nobody", Amount: 1000}, 0, -1, nil},
+		{"unstake", transaction.TxUnstake, &transaction.UnstakePayload{ValidatorID: "MRX-Val", Amount: 400}, 1000, 0, func(s *State, sender string) bool {
+			entries := s.Staking.PendingUnbondings(sender)
+			return len(entries) == 1 && entries[0].Amount == 400 && s.Staking.Validators["MRX-Val"].Delegators[sender] == 600
+		}},
+		{"unstake more than staked", transaction.TxUnstake, &transaction.UnstakePayload{ValidatorID: "MRX-Val", Amount: 1001}, 1000, -1, nil},
+		{"redelegate", transaction.TxRedelegate, &transaction.RedelegatePayload{SrcValidatorID: "MRX-Val", DstValidatorID: "MRX-Other", Amount: 1000}, 1000, 0, func(s *State, sender string) bool {
+			return s.Staking.Validators["MRX-Other"].Delegators[sender] == 1000 && len(s.Staking.PendingRedelegations(sender)) == 1
+		}},
+		{"register validator", transaction.TxRegisterValidator, &transaction.RegisterValidatorPayload{SelfBond: 10000, ConsensusPubKey: utils.EncodePublicKey(consensusPub), Moniker: "new"}, 0, 10000, func(s *State, sender string) bool {
+			validator := s.Staking.Validators[sender]
+			return validator != nil && validator.SelfBond() == 10000
+		}},
+		{"register with a self-bond above the balance", transaction.TxRegisterValidator, &transaction.RegisterValidatorPayload{SelfBond: 50000, ConsensusPubKey: utils.EncodePublicKey(consensusPub), Moniker: "new"}, 0, -1, nil},
+		{"unjail a validator that isn't jailed", transaction.TxUnjail, &transaction.UnjailPayload{}, 0, -1, nil},
+		{"withdraw without rewards", transaction.TxWithdrawRewards, &transaction.WithdrawRewardsPayload{}, 0, -1, nil},
+		{"vote on an unknown proposal", transaction.TxGovernanceVote, &transaction.GovernanceVotePayload{ProposalID: 99, Option: transaction.VoteYes}, 0, -1, nil},
+	} {
+		s := newTestState(t, DefaultEmissionSchedule(), 10000)
+		for _, id := range []string{"MRX-Val", "MRX-Other"} {
+			_, pub := utils.GenerateKeys()
+			if err := s.Staking.RegisterValidator(id, 10000, utils.EncodePublicKey(pub), staking.Commission{}, staking.Description{Moniker: id}, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		key, pub := utils.GenerateKeys()
+		sender := utils.PublicKeyToAddress(pub)
+		s.Balances[sender] = 20000
+		if tc.staked > 0 {
+			if err := s.Staking.Stake(sender, "MRX-Val", tc.staked); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var tx *transaction.Transaction
+		var err error
+		if tc.payload == nil {
+			tx, err = transaction.NewTransaction(sender, "MRX-Receiver", 100, 0, key)
+		} else {
+			tx, err = transaction.NewTypedTransaction(tc.txType, sender, tc.payload, 0, key)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		err = s.ApplyTransaction(tx)
+		if tc.debit < 0 {
+			if err == nil {
+				t.Errorf("%s: transaction applied", tc.name)
+			}
+			// A failed transaction costs nothing and doesn't use its nonce
+			if s.Balances[sender] != 20000 || s.Nonce(sender) != 0 {
+				t.Errorf("%s: failed transaction left a balance of %d and nonce %d", tc.name, s.Balances[sender], s.Nonce(sender))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if want := 20000 - tx.Fee - tc.debit; s.Balances[sender] != want {
+			t.Errorf("%s: balance is %d, want %d", tc.name, s.Balances[sender], want)
+		}
+		if s.Nonce(sender) != 1 {
+			t.Errorf("%s: nonce is %d, want 1", tc.name, s.Nonce(sender))
+		}
+		if !tc.check(s, sender) {
+			t.Errorf("%s: transaction had the wrong effect", tc.name)
+		}
+
+		// The same transaction can't be applied twice
+		if err := s.ApplyTransaction(tx); err == nil {
+			t.Errorf("%s: transaction replayed", tc.name)
+		}
+	}
+}
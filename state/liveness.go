@@ -0,0 +1,72 @@
+package state
+
+import (
+	"fmt"
+	"matrix-blockchain/blockchain"
+	"matrix-blockchain/staking"
+)
+
+// SigningInfo tracks the blocks a validator missed over a sliding window.
+type SigningInfo struct {
+	IndexOffset  int64  // Blocks tracked since the validator joined or was unjailed
+	MissedBlocks []bool // Ring buffer of the last SignedBlocksWindow blocks
+	MissedCount  int64  // Number of missed blocks in the window
+}
+
+// applyLastCommit records which of the validators that voted on the previous
+// block signed its encoded commit certificate, then jails and slashes validators that
+// missed too many blocks in the window. The certificate must already have
+// been verified against the validator set.
+func (s *State) applyLastCommit(lastCommit []byte) error {
+	if len(lastCommit) == 0 {
+		return nil
+	}
+	cert, err := blockchain.DecodeCommitCertificate(lastCommit)
+	if err != nil {
+		return err
+	}
+	if cert.Height != s.Height-1 {
+		return fmt.Errorf("last commit for height %d in block %d", cert.Height, s.Height)
+	}
+
+	params := s.Staking.Params
+	window := params.SignedBlocksWindow
+	maxMissed := window - window*params.MinSignedPerWindow/staking.BasisPoints
+	signers := cert.Signers()
+	for _, member := range s.LastActiveSet {
+		id := member.ID
+		validator, exists := s.Staking.Validators[id]
+		if !exists || validator.Jailed {
+			continue
+		}
+
+		info := s.SigningInfos[id]
+		if info == nil || int64(len(info.MissedBlocks)) != window {
+			info = &SigningInfo{MissedBlocks: make([]bool, window)}
+			s.SigningInfos[id] = info
+		}
+		index := info.IndexOffset % window
+		missed := !signers[id]
+		if info.MissedBlocks[index] && !missed {
+			info.MissedCount--
+		} else if !info.MissedBlocks[index] && missed {
+			info.MissedCount++
+		}
+		info.MissedBlocks[index] = missed
+		info.IndexOffset++
+
+		if info.IndexOffset < window || info.MissedCount <= maxMissed {
+			continue
+		}
+		burned, err := s.Staking.Slash(id, s.Height, params.SlashFractionDowntime)
+		if err != nil {
+			return err
+		}
+		s.burn(burned)
+		if err := s.Staking.Jail(id, s.Height+params.DowntimeJailDuration); err != nil {
+			return err
+		}
+		delete(s.SigningInfos, id)
+	}
+	return nil
+}
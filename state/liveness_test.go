@@ -0,0 +1,68 @@
+package state
+
+import (
+	"matrix-blockchain/blockchain"
+	"testing"
+)
+
+func TestDowntimeJailing(t *testing.T) {
+	for _, tc := range []struct {
+		blocks   string // Whether the validator signed each block, x for a miss
+		jailedAt int64  // Height the validator is jailed at, 0 if never
+	}{
+		{"........", 0},
+		{"xx......", 0},
+		{"xxx.", 4},            // Only judged once the window is full
+		{"..xxx...", 5},        // Third miss in the window
+		{"xx....xx", 0},        // Early misses roll out of the window
+		{"x.x.x.x.x.x.x.x", 0}, // Never more than half missed
+	} {
+		s := newTestState(t, DefaultEmissionSchedule(), 10000)
+		s.Staking.Params.SignedBlocksWindow = 4
+		s.Staking.Params.MinSignedPerWindow = 5000
+		s.Staking.Params.SlashFractionDowntime = 100
+		s.Staking.Params.DowntimeJailDuration = 10
+		id, _ := registerTestValidator(t, s, 20000, 0)
+		s.LastActiveSet = []ActiveValidator{{ID: id, Power: 20000}}
+
+		var jailedAt int64
+		for i, block := range tc.blocks {
+			height := int64(i + 2)
+			cert := &blockchain.CommitCertificate{Height: height - 1}
+			if block != 'x' {
+				cert.Precommits = []*blockchain.Vote{{Type: blockchain.Precommit, Height: height - 1, ValidatorID: id}}
+			}
+			lastCommit, err := cert.Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.BeginBlock(height, lastCommit); err != nil {
+				t.Fatal(err)
+			}
+			if s.Staking.Validators[id].Jailed && jailedAt == 0 {
+				jailedAt = int64(i + 1)
+			}
+		}
+
+		if jailedAt != tc.jailedAt {
+			t.Errorf("%s: jailed after block %d, want %d", tc.blocks, jailedAt, tc.jailedAt)
+			continue
+		}
+		validator := s.Staking.Validators[id]
+		if tc.jailedAt == 0 {
+			if validator.SelfBond() != 20000 {
+				t.Errorf("%s: validator that wasn't jailed was slashed", tc.blocks)
+			}
+			continue
+		}
+		if validator.SelfBond() != 19800 {
+			t.Errorf("%s: self-bond after a 1%% downtime slash is %d, want 19800", tc.blocks, validator.SelfBond())
+		}
+		if want := tc.jailedAt + 1 + 10; validator.JailedUntil != want {
+			t.Errorf("%s: jailed until %d, want %d", tc.blocks, validator.JailedUntil, want)
+		}
+		if s.SigningInfos[id] != nil {
+			t.Errorf("%s: missed blocks still counted after jailing", tc.blocks)
+		}
+	}
+}
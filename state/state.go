@@ -0,0 +1,194 @@
+package state
+
+import (
+	"fmt"
+	"matrix-blockchain/staking"
+	"matrix-blockchain/transaction"
+)
+
+// State is the chain state derived by applying the transactions of each block in order.
+type State struct {
+	Height   int64                        // Height of the last applied block
+	Balances map[string]int64             // Liquid balance per address
+	Nonces   map[string]uint64            // Next expected nonce per address
+	Staking  *staking.StakingSystem       // Validator and delegation state
+	Votes    map[uint64]map[string]string // Governance votes: proposal ID -> voter -> option
+	Grants   map[string]*Grant            // Research grants by ID
+	Vesting  map[string]*VestingAccount   // Vesting schedules by address
+	Slashed  map[string]bool              // Infractions already punished, by validator and height
+
+	SigningInfos  map[string]*SigningInfo // Liveness of each validator
+	ActiveSet     []ActiveValidator       // Validators voting on the next block
+	LastActiveSet []ActiveValidator       // Validators that voted on the last block
+
+	Emission    EmissionSchedule // Block reward schedule
+	Supply      Supply           // Tokens minted, burned and in existence
+	BlockSupply BlockSupply      // Supply change caused by the last block
+	Treasury    int64            // Research share of emission, spent only by governance grants
+
+	Proposals      map[uint64]*Proposal // Governance proposals by ID
+	NextProposalID uint64               // ID of the last proposal submitted
+}
+
+// NewState creates an empty chain state.
+func NewState(stakingSystem *staking.StakingSystem) *State {
+	return &State{
+		Balances: make(map[string]int64),
+		Nonces:   make(map[string]uint64),
+		Staking:  stakingSystem,
+		Votes:    make(map[uint64]map[string]string),
+		Grants:   make(map[string]*Grant),
+		Vesting:  make(map[string]*VestingAccount),
+		Slashed:  make(map[string]bool),
+
+		SigningInfos: make(map[string]*SigningInfo),
+		Proposals:    make(map[uint64]*Proposal),
+	}
+}
+
+// Copy returns a deep copy of the state so a block can be applied speculatively.
+func (s *State) Copy() *State {
+	c := NewState(s.Staking.Copy())
+	c.Height = s.Height
+	for addr, balance := range s.Balances {
+		c.Balances[addr] = balance
+	}
+	for addr, nonce := range s.Nonces {
+		c.Nonces[addr] = nonce
+	}
+	for id, votes := range s.Votes {
+		c.Votes[id] = make(map[string]string, len(votes))
+		for voter, option := range votes {
+			c.Votes[id][voter] = option
+		}
+	}
+	for id, grant := range s.Grants {
+		g := *grant
+		g.Milestones = append([]GrantMilestone(nil), grant.Milestones...)
+		c.Grants[id] = &g
+	}
+	for id, proposal := range s.Proposals {
+		p := *proposal
+		c.Proposals[id] = &p
+	}
+	c.NextProposalID = s.NextProposalID
+	for addr, vesting := range s.Vesting {
+		v := *vesting
+		c.Vesting[addr] = &v
+	}
+	for key := range s.Slashed {
+		c.Slashed[key] = true
+	}
+	for id, info := range s.SigningInfos {
+		i := *info
+		i.MissedBlocks = append([]bool(nil), info.MissedBlocks...)
+		c.SigningInfos[id] = &i
+	}
+	c.ActiveSet = append([]ActiveValidator(nil), s.ActiveSet...)
+	c.Emission = s.Emission
+	c.Supply = s.Supply
+	c.BlockSupply = s.BlockSupply
+	c.Treasury = s.Treasury
+	c.LastActiveSet = append([]ActiveValidator(nil), s.LastActiveSet...)
+	return c
+}
+
+// ApplyBlock records the signers of the previous block, applies the evidence
+// and every transaction in a validated block and returns the transaction
+// receipts. Either the whole block is applied or the state is left untouched.
+// Fees collected in the block are paid to the block's validator.
+func (s *State) ApplyBlock(block *transaction.Block) ([]transaction.Receipt, error) {
+	next := s.Copy()
+	if err := next.BeginBlock(int64(block.Index), block.LastCommit); err != nil {
+		return nil, fmt.Errorf("block %d: %v", block.Index, err)
+	}
+
+	for i := range block.Evidence {
+		if err := next.ApplyEvidence(&block.Evidence[i]); err != nil {
+			return nil, fmt.Errorf("block %d: evidence against %s: %v", block.Index, block.Evidence[i].ValidatorID, err)
+		}
+	}
+
+	var fees int64
+	receipts := make([]transaction.Receipt, 0, len(block.Transactions))
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		if err := next.ApplyTransaction(tx); err != nil {
+			return nil, fmt.Errorf("block %d: transaction %s: %v", block.Index, tx.Hash(), err)
+		}
+		fees += tx.Fee
+		receipts = append(receipts, transaction.NewReceipt(tx, block.Index, i))
+	}
+	next.credit(block.Validator, fees)
+	next.applyEmission()
+	next.endProposals()
+	for _, entry := range next.Staking.CompleteUnbondings(next.Height) {
+		next.credit(entry.Delegator, entry.Amount)
+	}
+	next.Staking.CompleteRedelegations(next.Height)
+	next.updateActiveSet()
+	next.BlockSupply = BlockSupply{
+		Height: next.Height,
+		Minted: next.Supply.Minted - s.Supply.Minted,
+		Burned: next.Supply.Burned - s.Supply.Burned,
+		Total:  next.Supply.Total,
+	}
+
+	*s = *next
+	return receipts, nil
+}
+
+// BeginBlock starts the block at height by recording the signers of the
+// previous block's commit certificate. It runs before the block's evidence
+// and transactions, both when a block is applied and when one is proposed,
+// so proposers check transactions against the state they are applied to.
+func (s *State) BeginBlock(height int64, lastCommit []byte) error {
+	s.Height = height
+	return s.applyLastCommit(lastCommit)
+}
+
+// ApplyTransaction checks the sender's nonce, charges the fee and dispatches
+// the transaction to the handler for its type. Signatures must already have
+// been verified. The caller is responsible for crediting the collected fee.
+func (s *State) ApplyTransaction(tx *transaction.Transaction) error {
+	if err := tx.ValidateBasic(); err != nil {
+		return err
+	}
+	if tx.Nonce != s.Nonces[tx.From] {
+		return fmt.Errorf("invalid nonce: got %d, expected %d", tx.Nonce, s.Nonces[tx.From])
+	}
+
+	handler, exists := handlers[tx.Type]
+	if !exists {
+		return fmt.Errorf("no handler for transaction type: %s", tx.Type)
+	}
+	if err := s.debit(tx.From, tx.Fee); err != nil {
+		return fmt.Errorf("cannot pay fee: %v", err)
+	}
+	if err := handler(s, tx); err != nil {
+		s.credit(tx.From, tx.Fee)
+		return err
+	}
+
+	s.Nonces[tx.From]++
+	return nil
+}
+
+// debit removes funds from an address, failing if the spendable balance is insufficient.
+func (s *State) debit(addr string, amount int64) error {
+	if s.SpendableBalance(addr) < amount {
+		return fmt.Errorf("insufficient balance: %s has %d spendable, needs %d", addr, s.SpendableBalance(addr), amount)
+	}
+	s.Balances[addr] -= amount
+	return nil
+}
+
+// credit adds funds to an address.
+func (s *State) credit(addr string, amount int64) {
+	s.Balances[addr] += amount
+}
+
+// Nonce returns the nonce the next transaction from an address must carry.
+func (s *State) Nonce(addr string) uint64 {
+	return s.Nonces[addr]
+}
@@ -0,0 +1,36 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"matrix-blockchain/staking"
+)
+
+// Encode serializes the state for the chain state store.
+func (s *State) Encode() ([]byte, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode state: %v", err)
+	}
+	return data, nil
+}
+
+// DecodeState restores a state saved with Encode.
+func DecodeState(data []byte) (*State, error) {
+	s := NewState(staking.NewStakingSystem())
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to decode state: %v", err)
+	}
+	if s.Staking.RewardBalances == nil {
+		s.Staking.RewardBalances = make(map[string]int64)
+	}
+	for _, validator := range s.Staking.Validators {
+		if validator.Delegators == nil {
+			validator.Delegators = make(map[string]int64)
+		}
+		if validator.RewardStarts == nil {
+			validator.RewardStarts = make(map[string]uint64)
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,75 @@
+package state
+
+import "fmt"
+
+// Supply tracks the tokens in existence.
+type Supply struct {
+	Genesis int64 `json:"genesis"` // Tokens allocated at genesis
+	Minted  int64 `json:"minted"`  // Tokens minted by emission since genesis
+	Burned  int64 `json:"burned"`  // Tokens destroyed since genesis
+	Total   int64 `json:"total"`   // Tokens in existence: genesis plus minted minus burned
+}
+
+// BlockSupply records how a block changed the supply.
+type BlockSupply struct {
+	Height int64 `json:"height"`
+	Minted int64 `json:"minted"`
+	Burned int64 `json:"burned"`
+	Total  int64 `json:"total"` // Supply after the block
+}
+
+// SupplyReport breaks the supply down by where the tokens are.
+type SupplyReport struct {
+	Height      int64 `json:"height"`
+	Genesis     int64 `json:"genesis"`
+	Minted      int64 `json:"minted"`
+	Burned      int64 `json:"burned"`
+	Total       int64 `json:"total"`
+	Circulating int64 `json:"circulating"` // Spendable account balances
+	Staked      int64 `json:"staked"`      // Bonded to validators
+	Locked      int64 `json:"locked"`      // Vesting, unbonding, unwithdrawn rewards, treasury and reserved grants
+}
+
+// burn records tokens that have been destroyed.
+func (s *State) burn(amount int64) {
+	s.Supply.Total -= amount
+	s.Supply.Burned += amount
+}
+
+// SupplyReport accounts for every token in existence.
+func (s *State) SupplyReport() SupplyReport {
+	report := SupplyReport{
+		Height:  s.Height,
+		Genesis: s.Supply.Genesis,
+		Minted:  s.Supply.Minted,
+		Burned:  s.Supply.Burned,
+		Total:   s.Supply.Total,
+		Staked:  s.Staking.TotalStake(),
+		Locked:  s.Staking.OutstandingRewards + s.Treasury,
+	}
+	for addr, balance := range s.Balances {
+		spendable := s.SpendableBalance(addr)
+		report.Circulating += spendable
+		report.Locked += balance - spendable
+	}
+	for _, entry := range s.Staking.Unbondings {
+		report.Locked += entry.Amount
+	}
+	for _, grant := range s.Grants {
+		report.Locked += grant.Reserved()
+	}
+	return report
+}
+
+// CheckSupply verifies that the supply counters agree with each other and
+// with the tokens actually held in the state.
+func (s *State) CheckSupply() error {
+	report := s.SupplyReport()
+	if report.Total != report.Genesis+report.Minted-report.Burned {
+		return fmt.Errorf("supply %d does not match genesis %d plus minted %d minus burned %d", report.Total, report.Genesis, report.Minted, report.Burned)
+	}
+	if held := report.Circulating + report.Staked + report.Locked; held != report.Total {
+		return fmt.Errorf("state holds %d tokens, supply is %d", held, report.Total)
+	}
+	return nil
+}
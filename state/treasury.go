@@ -0,0 +1,83 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"matrix-blockchain/transaction"
+	"strconv"
+)
+
+// Grant is a research grant awarded by governance from the treasury. Its
+// funds are reserved when it is awarded and paid out milestone by milestone.
+type Grant struct {
+	ID            string
+	ProposalID    uint64
+	Recipient     string
+	Amount        int64
+	Milestones    []GrantMilestone
+	AwardedHeight int64
+	Claimed       bool // Every milestone has been paid
+}
+
+// GrantMilestone is a milestone of a grant and when it was paid.
+type GrantMilestone struct {
+	transaction.Milestone
+	PaidHeight int64 `json:"paid_height"` // 0 while unpaid
+}
+
+// Reserved returns the part of the grant that hasn't been paid yet.
+func (g *Grant) Reserved() int64 {
+	var reserved int64
+	for _, milestone := range g.Milestones {
+		if milestone.PaidHeight == 0 {
+			reserved += milestone.Amount
+		}
+	}
+	return reserved
+}
+
+// awardGrant reserves the funds of an approved treasury grant proposal.
+func (s *State) awardGrant(proposal *Proposal) error {
+	amount := proposal.Grant.Total()
+	if s.Treasury < amount {
+		return fmt.Errorf("treasury holds %d, grant needs %d", s.Treasury, amount)
+	}
+
+	grant := &Grant{
+		ID:            strconv.FormatUint(proposal.ID, 10),
+		ProposalID:    proposal.ID,
+		Recipient:     proposal.Grant.Recipient,
+		Amount:        amount,
+		AwardedHeight: s.Height,
+	}
+	for _, milestone := range proposal.Grant.Milestones {
+		grant.Milestones = append(grant.Milestones, GrantMilestone{Milestone: milestone})
+	}
+	s.Treasury -= amount
+	s.Grants[grant.ID] = grant
+	return nil
+}
+
+// claimGrant pays the recipient every milestone of a grant that has unlocked
+// and returns the amount paid.
+func (s *State) claimGrant(grant *Grant) (int64, error) {
+	var paid int64
+	claimed := true
+	for i := range grant.Milestones {
+		milestone := &grant.Milestones[i]
+		if milestone.PaidHeight == 0 && milestone.UnlockHeight <= s.Height {
+			milestone.PaidHeight = s.Height
+			paid += milestone.Amount
+		}
+		if milestone.PaidHeight == 0 {
+			claimed = false
+		}
+	}
+	if paid == 0 {
+		return 0, errors.New("no grant milestone has unlocked")
+	}
+
+	grant.Claimed = claimed
+	s.credit(grant.Recipient, paid)
+	return paid, nil
+}
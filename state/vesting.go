@@ -0,0 +1,67 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"matrix-blockchain/utils"
+)
+
+// VestingAccount locks part of an account's balance and releases it linearly
+// between StartHeight and EndHeight.
+type VestingAccount struct {
+	Address         string `json:"address"`
+	OriginalVesting int64  `json:"original_vesting"` // Amount locked at genesis
+	StartHeight     int64  `json:"start_height"`     // Height at which unlocking begins
+	EndHeight       int64  `json:"end_height"`       // Height at which everything is unlocked
+}
+
+// Validate checks the vesting schedule.
+func (v *VestingAccount) Validate() error {
+	if !utils.ValidateAddress(v.Address) {
+		return fmt.Errorf("invalid vesting account address: %s", v.Address)
+	}
+	if v.OriginalVesting <= 0 {
+		return fmt.Errorf("vesting amount for %s must be positive", v.Address)
+	}
+	if v.StartHeight < 0 || v.EndHeight <= v.StartHeight {
+		return errors.New("vesting end height must be after start height")
+	}
+	return nil
+}
+
+// LockedAt returns the amount still locked at the given height.
+func (v *VestingAccount) LockedAt(height int64) int64 {
+	if height <= v.StartHeight {
+		return v.OriginalVesting
+	}
+	if height >= v.EndHeight {
+		return 0
+	}
+	// The product of the amount and the remaining blocks can overflow int64.
+	// Round the locked amount up so unlocking never runs ahead of schedule.
+	duration := big.NewInt(v.EndHeight - v.StartHeight)
+	locked := new(big.Int).Mul(big.NewInt(v.OriginalVesting), big.NewInt(v.EndHeight-height))
+	locked.Add(locked, duration)
+	locked.Sub(locked, big.NewInt(1))
+	locked.Quo(locked, duration)
+	return locked.Int64()
+}
+
+// LockedBalance returns the part of an address's balance still locked by vesting.
+func (s *State) LockedBalance(addr string) int64 {
+	vesting, exists := s.Vesting[addr]
+	if !exists {
+		return 0
+	}
+	return vesting.LockedAt(s.Height)
+}
+
+// SpendableBalance returns the balance an address may transfer or stake.
+func (s *State) SpendableBalance(addr string) int64 {
+	spendable := s.Balances[addr] - s.LockedBalance(addr)
+	if spendable < 0 {
+		return 0
+	}
+	return spendable
+}
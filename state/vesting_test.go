@@ -0,0 +1,27 @@
+package state
+
+import (
+	"math"
+	"testing"
+)
+
+func TestVestingLockedAt(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		vesting VestingAccount
+		height  int64
+		want    int64
+	}{
+		{"before start", VestingAccount{OriginalVesting: 1000, StartHeight: 100, EndHeight: 200}, 50, 1000},
+		{"halfway", VestingAccount{OriginalVesting: 1000, StartHeight: 100, EndHeight: 200}, 150, 500},
+		{"rounded up", VestingAccount{OriginalVesting: 10, StartHeight: 0, EndHeight: 3}, 1, 7},
+		{"at end", VestingAccount{OriginalVesting: 1000, StartHeight: 100, EndHeight: 200}, 200, 0},
+		// OriginalVesting times the remaining blocks overflows int64
+		{"large amount", VestingAccount{OriginalVesting: math.MaxInt64 / 2, StartHeight: 0, EndHeight: 1000}, 500, math.MaxInt64/4 + 1},
+		{"large amount at start", VestingAccount{OriginalVesting: math.MaxInt64, StartHeight: 0, EndHeight: 100000000}, 1, math.MaxInt64 - 92233720368},
+	} {
+		if got := tc.vesting.LockedAt(tc.height); got != tc.want {
+			t.Errorf("%s: locked %d at height %d, want %d", tc.name, got, tc.height, tc.want)
+		}
+	}
+}
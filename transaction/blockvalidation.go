@@ -8,17 +8,48 @@ import (
 
 // Block represents a single block in the blockchain.
 type Block struct {
-	Index        int           // Position of the block in the blockchain
-	PreviousHash string        // Hash of the previous block
-	Timestamp    int64         // Timestamp of block creation
-	Transactions []Transaction // List of transactions in the block
-	Hash         string        // Hash of this block
-	Validator    string        // Validator who created this block
-	Signature    string        // Validator's signature for the block
+	Index          int           // Position of the block in the blockchain
+	PreviousHash   string        // Hash of the previous block
+	Timestamp      int64         // Timestamp of block creation
+	Transactions   []Transaction // List of transactions in the block
+	Evidence       []Evidence    // Proof of validator misbehaviour
+	LastCommit     []byte        // Encoded commit certificate of the previous block
+	Hash           string        // Hash of this block
+	ValidatorsHash string        // Hash of the validator set voting on this block
+	Validator      string        // Validator who created this block
+	Signature      string        // Validator's signature for the block
+}
+
+// NewBlock builds the block following previousBlock and computes its hash.
+func NewBlock(previousBlock Block, transactions []Transaction, evidence []Evidence, lastCommit []byte, validatorsHash string, validator string, timestamp int64) Block {
+	block := Block{
+		Index:          previousBlock.Index + 1,
+		PreviousHash:   previousBlock.Hash,
+		Timestamp:      timestamp,
+		Transactions:   transactions,
+		Evidence:       evidence,
+		LastCommit:     lastCommit,
+		ValidatorsHash: validatorsHash,
+		Validator:      validator,
+	}
+	block.Hash = calculateBlockHash(block)
+	return block
+}
+
+// NewGenesisBlock builds the first block of the chain.
+func NewGenesisBlock(timestamp int64) Block {
+	block := Block{
+		Index:     0,
+		Timestamp: timestamp,
+		Validator: "MRX-GENESIS",
+	}
+	block.Hash = calculateBlockHash(block)
+	return block
 }
 
 // ValidateBlock ensures that the block adheres to blockchain rules.
-func ValidateBlock(newBlock, previousBlock Block) error {
+// Signatures already present in sigCache are not verified again.
+func ValidateBlock(newBlock, previousBlock Block, sigCache *SignatureCache) error {
 	// Check if the block index is valid
 	if newBlock.Index != previousBlock.Index+1 {
 		return fmt.Errorf("invalid index: got %d, expected %d", newBlock.Index, previousBlock.Index+1)
@@ -29,6 +60,11 @@ func ValidateBlock(newBlock, previousBlock Block) error {
 		return fmt.Errorf("invalid previous hash: got %s, expected %s", newBlock.PreviousHash, previousBlock.Hash)
 	}
 
+	// Block time must move forward; time locks are checked against it
+	if newBlock.Timestamp <= previousBlock.Timestamp {
+		return fmt.Errorf("invalid timestamp: %d is not after the previous block's %d", newBlock.Timestamp, previousBlock.Timestamp)
+	}
+
 	// Recalculate the hash of the new block and compare
 	calculatedHash := calculateBlockHash(newBlock)
 	if newBlock.Hash != calculatedHash {
@@ -37,11 +73,32 @@ func ValidateBlock(newBlock, previousBlock Block) error {
 
 	// Validate all transactions in the block
 	for _, tx := range newBlock.Transactions {
-		if !tx.Verify() {
-			return fmt.Errorf("invalid transaction in block: %v", tx)
+		if err := tx.ValidateBasic(); err != nil {
+			return fmt.Errorf("invalid transaction %s in block: %v", tx.Hash(), err)
+		}
+		if err := tx.CheckTimeLock(int64(newBlock.Index), newBlock.Timestamp); err != nil {
+			return err
 		}
 	}
 
+	// Check the evidence is well formed; it is verified against the chain state
+	if len(newBlock.Evidence) > MaxBlockEvidence {
+		return fmt.Errorf("too much evidence in block: %d, max %d", len(newBlock.Evidence), MaxBlockEvidence)
+	}
+	for _, evidence := range newBlock.Evidence {
+		if err := evidence.ValidateBasic(); err != nil {
+			return fmt.Errorf("invalid evidence in block: %v", err)
+		}
+		if evidence.Height >= int64(newBlock.Index) {
+			return fmt.Errorf("evidence for height %d in block %d", evidence.Height, newBlock.Index)
+		}
+	}
+
+	// Verify all signatures in parallel
+	if err := VerifyTransactions(newBlock.Transactions, sigCache); err != nil {
+		return fmt.Errorf("invalid transaction in block: %v", err)
+	}
+
 	// (Optional) Validate the validator's signature
 	if !utils.ValidateAddress(newBlock.Validator) {
 		return fmt.Errorf("invalid validator address: %s", newBlock.Validator)
@@ -52,7 +109,16 @@ func ValidateBlock(newBlock, previousBlock Block) error {
 
 // calculateBlockHash calculates the hash for a block.
 func calculateBlockHash(block Block) string {
-	data := fmt.Sprintf("%d%s%d%s%s", block.Index, block.PreviousHash, block.Timestamp, block.Transactions, block.Validator)
+	txHashes := make([]string, len(block.Transactions))
+	for i := range block.Transactions {
+		txHashes[i] = block.Transactions[i].Hash()
+	}
+	evidenceHashes := make([]string, len(block.Evidence))
+	for i := range block.Evidence {
+		evidenceHashes[i] = block.Evidence[i].Hash()
+	}
+	data := fmt.Sprintf("%d%s%d%v%v%s%s%s", block.Index, block.PreviousHash, block.Timestamp, txHashes, evidenceHashes,
+		utils.Hash(block.LastCommit), block.ValidatorsHash, block.Validator)
 	hash := sha256.Sum256([]byte(data))
 	return fmt.Sprintf("%x", hash)
 }
@@ -60,7 +126,7 @@ func calculateBlockHash(block Block) string {
 // IsValidChain verifies the integrity of the entire blockchain.
 func IsValidChain(blockchain []Block) error {
 	for i := 1; i < len(blockchain); i++ {
-		err := ValidateBlock(blockchain[i], blockchain[i-1])
+		err := ValidateBlock(blockchain[i], blockchain[i-1], nil)
 		if err != nil {
 			return fmt.Errorf("blockchain validation failed at block %d: %v", i, err)
 		}
@@ -0,0 +1,24 @@
+package transaction
+
+import "testing"
+
+func TestValidateBlockRequiresLaterTimestamp(t *testing.T) {
+	genesis := NewGenesisBlock(1000)
+	for _, tc := range []struct {
+		timestamp int64
+		valid     bool
+	}{
+		{999, false},
+		{1000, false},
+		{1001, true},
+	} {
+		block := NewBlock(genesis, nil, nil, nil, "", "MRX-Proposer", tc.timestamp)
+		err := ValidateBlock(block, genesis, nil)
+		if tc.valid && err != nil {
+			t.Errorf("timestamp %d rejected: %v", tc.timestamp, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("timestamp %d accepted after parent at 1000", tc.timestamp)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package transaction
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"matrix-blockchain/utils"
+)
+
+// MaxBlockEvidence is the maximum number of evidence items in a block.
+const MaxBlockEvidence = 100
+
+// Evidence is proof of validator misbehaviour carried in a block. Data holds
+// the type-specific proof, decoded and verified by the chain state.
+type Evidence struct {
+	Type        string          `json:"type"`
+	ValidatorID string          `json:"validator_id"`
+	Height      int64           `json:"height"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// Hash returns the hash of the evidence.
+func (e *Evidence) Hash() string {
+	data, _ := json.Marshal(e)
+	return utils.Hash(data)
+}
+
+// ValidateBasic performs stateless checks on the evidence.
+func (e *Evidence) ValidateBasic() error {
+	if e.Type == "" {
+		return errors.New("evidence requires a type")
+	}
+	if e.ValidatorID == "" {
+		return errors.New("evidence requires a validator ID")
+	}
+	if e.Height <= 0 {
+		return fmt.Errorf("invalid evidence height %d", e.Height)
+	}
+	if len(e.Data) == 0 {
+		return errors.New("evidence is empty")
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package transaction
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MaxMemoSize    = 256 // Maximum memo length in bytes
+	BaseFee        = 1   // Fee charged for every transaction
+	FeePerMemoByte = 1   // Additional fee per byte of memo
+)
+
+// RequiredFee returns the minimum fee the transaction must pay.
+func (t *Transaction) RequiredFee() int64 {
+	return BaseFee + FeePerMemoByte*int64(len(t.Memo))
+}
+
+// validateFee checks the memo size and content and that the fee covers its
+// cost. Memos are UTF-8 text without control characters, which keeps them
+// printable and out of the separators of the memo tag index.
+func (t *Transaction) validateFee() error {
+	if len(t.Memo) > MaxMemoSize {
+		return fmt.Errorf("memo is %d bytes, maximum is %d", len(t.Memo), MaxMemoSize)
+	}
+	if !utf8.ValidString(t.Memo) {
+		return errors.New("memo is not valid UTF-8")
+	}
+	if strings.IndexFunc(t.Memo, unicode.IsControl) >= 0 {
+		return errors.New("memo contains control characters")
+	}
+	if t.Fee < t.RequiredFee() {
+		return fmt.Errorf("insufficient fee: got %d, required %d", t.Fee, t.RequiredFee())
+	}
+	return nil
+}
+
+// SetMemo attaches a memo, raises the fee to cover it and re-signs the transaction.
+func (t *Transaction) SetMemo(memo string, privateKey *ecdsa.PrivateKey) error {
+	t.Memo = memo
+	if t.Fee < t.RequiredFee() {
+		t.Fee = t.RequiredFee()
+	}
+	if err := t.validateFee(); err != nil {
+		return err
+	}
+	return t.Sign(privateKey)
+}
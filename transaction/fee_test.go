@@ -0,0 +1,33 @@
+package transaction
+
+import (
+	"matrix-blockchain/utils"
+	"testing"
+)
+
+func TestMemoMustBePrintableText(t *testing.T) {
+	key, pub := utils.GenerateKeys()
+	for _, tc := range []struct {
+		memo  string
+		valid bool
+	}{
+		{"grant:42", true},
+		{"paiement reçu", true},
+		{"grant\x00x:1", false},
+		{"line\nbreak", false},
+		{"\x7f", false},
+		{"\xff\xfe", false},
+	} {
+		tx, err := NewTransaction(utils.PublicKeyToAddress(pub), "MRX-Receiver", 10, 0, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tx.SetMemo(tc.memo, key)
+		if tc.valid && err != nil {
+			t.Errorf("memo %q rejected: %v", tc.memo, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("memo %q accepted", tc.memo)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// MaxNonceGap is how far ahead of its sender's next nonce a transaction's
+// nonce may be to wait in the mempool.
+const MaxNonceGap = 64
+
+// AccountState is the chain state the mempool checks transactions against.
+type AccountState interface {
+	Nonce(addr string) uint64
+	SpendableBalance(addr string) int64
+}
+
+// Mempool holds verified transactions waiting to be included in a block.
+type Mempool struct {
+	mutex    sync.Mutex
+	txs      map[string]*Transaction // Pending transactions by hash
+	order    []string                // Arrival order of pending transactions
+	sigCache *SignatureCache         // Shared with block validation
+	MaxSize  int                     // Maximum number of pending transactions
+
+	height    int64 // Height of the latest committed block
+	blockTime int64 // Timestamp of the latest committed block
+}
+
+// NewMempool creates a mempool that records verified signatures in sigCache.
+func NewMempool(maxSize int, sigCache *SignatureCache) *Mempool {
+	return &Mempool{
+		txs:      make(map[string]*Transaction),
+		sigCache: sigCache,
+		MaxSize:  maxSize,
+	}
+}
+
+// Add admits a transaction after stateless validation, a check against the
+// sender's account and signature verification.
+func (m *Mempool) Add(tx *Transaction, accounts AccountState) error {
+	if err := tx.ValidateBasic(); err != nil {
+		return err
+	}
+	if err := checkAccount(tx, accounts); err != nil {
+		return err
+	}
+
+	hash := tx.Hash()
+	m.mutex.Lock()
+	expired := tx.IsExpired(m.height+1, m.blockTime)
+	_, exists := m.txs[hash]
+	full := len(m.txs) >= m.MaxSize
+	m.mutex.Unlock()
+	if expired {
+		return fmt.Errorf("transaction %s has expired", hash)
+	}
+	if exists {
+		return fmt.Errorf("transaction %s already in mempool", hash)
+	}
+	if full {
+		return errors.New("mempool is full")
+	}
+
+	// Verify outside the lock, signature checks are the expensive part
+	if !VerifyWithCache(tx, m.sigCache) {
+		return fmt.Errorf("invalid signature on transaction %s", hash)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, exists := m.txs[hash]; exists {
+		return fmt.Errorf("transaction %s already in mempool", hash)
+	}
+	m.txs[hash] = tx
+	m.order = append(m.order, hash)
+	return nil
+}
+
+// Pending returns up to limit transactions in arrival order that may be
+// included in a block at the given height and time. Time-locked transactions
+// that are not yet valid stay in the mempool.
+func (m *Mempool) Pending(limit int, height int64, blockTime int64) []Transaction {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var pending []Transaction
+	for _, hash := range m.order {
+		if len(pending) >= limit {
+			break
+		}
+		tx := m.txs[hash]
+		if tx.CheckTimeLock(height, blockTime) != nil {
+			continue
+		}
+		pending = append(pending, *tx)
+	}
+	return pending
+}
+
+// checkAccount checks that a transaction's nonce hasn't been used and isn't
+// too far ahead, and that its sender can pay its fee and what it debits.
+func checkAccount(tx *Transaction, accounts AccountState) error {
+	nonce := accounts.Nonce(tx.From)
+	if tx.Nonce < nonce {
+		return fmt.Errorf("nonce %d already used, expected %d", tx.Nonce, nonce)
+	}
+	if tx.Nonce > nonce+MaxNonceGap {
+		return fmt.Errorf("nonce %d too far ahead of %d", tx.Nonce, nonce)
+	}
+	debit := tx.Debit()
+	if spendable := accounts.SpendableBalance(tx.From); spendable < tx.Fee || spendable-tx.Fee < debit {
+		return fmt.Errorf("insufficient balance: %s has %d spendable, needs %d for the fee and %d", tx.From, spendable, tx.Fee, debit)
+	}
+	return nil
+}
+
+// Update records the latest committed block and evicts transactions that
+// expired or that the committed state rules out.
+func (m *Mempool) Update(height int64, blockTime int64, accounts AccountState) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.height = height
+	m.blockTime = blockTime
+	remaining := m.order[:0]
+	for _, hash := range m.order {
+		tx := m.txs[hash]
+		if tx.IsExpired(height+1, blockTime) || checkAccount(tx, accounts) != nil {
+			delete(m.txs, hash)
+			continue
+		}
+		remaining = append(remaining, hash)
+	}
+	m.order = remaining
+}
+
+// Remove drops transactions, such as those included in a committed block.
+func (m *Mempool) Remove(txs []Transaction) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i := range txs {
+		delete(m.txs, txs[i].Hash())
+	}
+	remaining := m.order[:0]
+	for _, hash := range m.order {
+		if _, exists := m.txs[hash]; exists {
+			remaining = append(remaining, hash)
+		}
+	}
+	m.order = remaining
+}
+
+// Size returns the number of pending transactions.
+func (m *Mempool) Size() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.txs)
+}
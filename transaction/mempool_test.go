@@ -0,0 +1,106 @@
+package transaction
+
+import (
+	"crypto/ecdsa"
+	"matrix-blockchain/utils"
+	"testing"
+)
+
+// testAccounts is an in-memory AccountState.
+type testAccounts struct {
+	nonces   map[string]uint64
+	balances map[string]int64
+}
+
+func (a *testAccounts) Nonce(addr string) uint64           { return a.nonces[addr] }
+func (a *testAccounts) SpendableBalance(addr string) int64 { return a.balances[addr] }
+
+func newTestTransfer(t *testing.T, key *ecdsa.PrivateKey, nonce uint64) *Transaction {
+	t.Helper()
+	tx, err := NewTransaction(utils.PublicKeyToAddress(&key.PublicKey), "MRX-Receiver", 10, nonce, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestMempoolRejectsUnfundedAndStaleTransactions(t *testing.T) {
+	key, pub := utils.GenerateKeys()
+	sender := utils.PublicKeyToAddress(pub)
+	accounts := &testAccounts{nonces: map[string]uint64{sender: 5}, balances: map[string]int64{}}
+	pool := NewMempool(10, nil)
+
+	if err := pool.Add(newTestTransfer(t, key, 5), accounts); err == nil {
+		t.Error("admitted a transaction its sender can't pay for")
+	}
+	accounts.balances[sender] = 1000
+	if err := pool.Add(newTestTransfer(t, key, 4), accounts); err == nil {
+		t.Error("admitted a transaction with a used nonce")
+	}
+	if err := pool.Add(newTestTransfer(t, key, 5+MaxNonceGap+1), accounts); err == nil {
+		t.Error("admitted a transaction with a nonce too far ahead")
+	}
+	if err := pool.Add(newTestTransfer(t, key, 5), accounts); err != nil {
+		t.Errorf("rejected a valid transaction: %v", err)
+	}
+}
+
+func TestMempoolUpdateEvictsStaleTransactions(t *testing.T) {
+	key, pub := utils.GenerateKeys()
+	sender := utils.PublicKeyToAddress(pub)
+	accounts := &testAccounts{nonces: map[string]uint64{}, balances: map[string]int64{sender: 1000}}
+	pool := NewMempool(10, nil)
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		if err := pool.Add(newTestTransfer(t, key, nonce), accounts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A block elsewhere used nonces 0 and 1
+	accounts.nonces[sender] = 2
+	pool.Update(1, 0, accounts)
+	if pool.Size() != 1 {
+		t.Errorf("mempool holds %d transactions, want 1", pool.Size())
+	}
+
+	// The sender's funds are gone
+	accounts.balances[sender] = 0
+	pool.Update(2, 0, accounts)
+	if pool.Size() != 0 {
+		t.Errorf("mempool holds %d transactions, want 0", pool.Size())
+	}
+}
+
+func TestMempoolChecksPayloadDebits(t *testing.T) {
+	_, consensusPub := utils.GenerateKeys()
+	for _, tc := range []struct {
+		name    string
+		txType  TxType
+		payload Payload
+		debit   int64
+	}{
+		{"stake", TxStake, &StakePayload{ValidatorID: "MRX-Validator", Amount: 500}, 500},
+		{"register", TxRegisterValidator, &RegisterValidatorPayload{SelfBond: 10000, ConsensusPubKey: utils.EncodePublicKey(consensusPub), Moniker: "validator"}, 10000},
+		{"unstake", TxUnstake, &UnstakePayload{ValidatorID: "MRX-Validator", Amount: 500}, 0},
+	} {
+		key, pub := utils.GenerateKeys()
+		sender := utils.PublicKeyToAddress(pub)
+		tx, err := NewTypedTransaction(tc.txType, sender, tc.payload, 0, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx.Debit() != tc.debit {
+			t.Errorf("%s: debits %d, want %d", tc.name, tx.Debit(), tc.debit)
+		}
+
+		// One token short of the fee and the debit
+		accounts := &testAccounts{nonces: map[string]uint64{}, balances: map[string]int64{sender: tx.Fee + tc.debit - 1}}
+		if err := NewMempool(10, nil).Add(tx, accounts); err == nil {
+			t.Errorf("%s: admitted with %d spendable for a fee of %d and a debit of %d", tc.name, accounts.balances[sender], tx.Fee, tc.debit)
+		}
+		accounts.balances[sender]++
+		if err := NewMempool(10, nil).Add(tx, accounts); err != nil {
+			t.Errorf("%s: rejected with enough balance: %v", tc.name, err)
+		}
+	}
+}
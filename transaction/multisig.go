@@ -0,0 +1,102 @@
+package transaction
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"matrix-blockchain/utils"
+)
+
+// MaxMultisigKeys bounds the key set of a multisig sender, so checking a
+// transaction's signatures stays cheap.
+const MaxMultisigKeys = 20
+
+// MultisigInfo describes the M-of-N key set controlling a multisig sender.
+type MultisigInfo struct {
+	Threshold  int      // Number of signatures required
+	PublicKeys [][]byte // Encoded public keys of all signers
+}
+
+// MultisigSignature is a signature by the key at Index in MultisigInfo.PublicKeys.
+type MultisigSignature struct {
+	Index     int
+	Signature *Signature
+}
+
+// Address returns the multisig address derived from the key set.
+func (m *MultisigInfo) Address() (string, error) {
+	return utils.MultisigAddress(m.Threshold, m.PublicKeys)
+}
+
+// SignMultisig adds one signer's signature to a transaction sent from a multisig address.
+func (t *Transaction) SignMultisig(info *MultisigInfo, privateKey *ecdsa.PrivateKey) error {
+	encoded := utils.EncodePublicKey(&privateKey.PublicKey)
+	index := -1
+	for i, key := range info.PublicKeys {
+		if string(key) == string(encoded) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return errors.New("signing key is not part of the multisig")
+	}
+
+	r, s, err := utils.SignTransaction(privateKey, t.SigningBytes())
+	if err != nil {
+		return fmt.Errorf("failed to sign transaction: %v", err)
+	}
+	t.Multisig = info
+	t.Signatures = append(t.Signatures, MultisigSignature{Index: index, Signature: &Signature{R: r, S: s}})
+	return nil
+}
+
+// validateMultisig checks the size of a multisig key set and that there are
+// no more signatures than keys.
+func (t *Transaction) validateMultisig() error {
+	if t.Multisig == nil {
+		if len(t.Signatures) > 0 {
+			return errors.New("multisig signatures without a multisig key set")
+		}
+		return nil
+	}
+	if len(t.Multisig.PublicKeys) > MaxMultisigKeys {
+		return fmt.Errorf("multisig has %d keys, the maximum is %d", len(t.Multisig.PublicKeys), MaxMultisigKeys)
+	}
+	if len(t.Signatures) > len(t.Multisig.PublicKeys) {
+		return fmt.Errorf("%d signatures for a multisig of %d keys", len(t.Signatures), len(t.Multisig.PublicKeys))
+	}
+	return nil
+}
+
+// verifyMultisig checks that the key set matches the sender address and that
+// at least Threshold distinct signers produced a valid signature. Signatures
+// after the Threshold-th valid one are not checked.
+func (t *Transaction) verifyMultisig() bool {
+	address, err := t.Multisig.Address()
+	if err != nil || address != t.From {
+		return false
+	}
+
+	data := t.SigningBytes()
+	signed := make(map[int]bool)
+	for _, sig := range t.Signatures {
+		if sig.Index < 0 || sig.Index >= len(t.Multisig.PublicKeys) || signed[sig.Index] {
+			continue
+		}
+		if sig.Signature == nil || sig.Signature.R == nil || sig.Signature.S == nil {
+			continue
+		}
+		publicKey, err := utils.DecodePublicKey(t.Multisig.PublicKeys[sig.Index])
+		if err != nil {
+			continue
+		}
+		if utils.VerifySignature(publicKey, data, sig.Signature.R, sig.Signature.S) {
+			signed[sig.Index] = true
+			if len(signed) == t.Multisig.Threshold {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package transaction
+
+import (
+	"crypto/ecdsa"
+	"matrix-blockchain/utils"
+	"testing"
+	"time"
+)
+
+// newTestMultisig creates the keys of an M-of-N multisig and an unsigned
+// transfer from its address.
+func newTestMultisig(t *testing.T, threshold, signers int) ([]*ecdsa.PrivateKey, *MultisigInfo, *Transaction) {
+	t.Helper()
+	keys := make([]*ecdsa.PrivateKey, signers)
+	info := &MultisigInfo{Threshold: threshold}
+	for i := range keys {
+		key, pub := utils.GenerateKeys()
+		keys[i] = key
+		info.PublicKeys = append(info.PublicKeys, utils.EncodePublicKey(pub))
+	}
+	address, err := info.Address()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := &Transaction{Type: TxTransfer, From: address, To: "MRX-Receiver", Amount: 10, Timestamp: time.Now().Unix(), Fee: BaseFee}
+	return keys, info, tx
+}
+
+func TestMultisigThreshold(t *testing.T) {
+	keys, info, tx := newTestMultisig(t, 2, 3)
+
+	if err := tx.SignMultisig(info, keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	if tx.Verify() {
+		t.Error("1 of 2 required signatures verified")
+	}
+
+	// The same signer twice still counts once
+	if err := tx.SignMultisig(info, keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	if tx.Verify() {
+		t.Error("a repeated signature counted twice")
+	}
+
+	if err := tx.SignMultisig(info, keys[2]); err != nil {
+		t.Fatal(err)
+	}
+	if !tx.Verify() {
+		t.Error("2 of 2 required signatures rejected")
+	}
+
+	// Signatures stop verifying once the transaction changes
+	tx.Amount = 11
+	if tx.Verify() {
+		t.Error("signatures verified for a modified transaction")
+	}
+}
+
+func TestMultisigRejectsOutsidersAndOtherKeySets(t *testing.T) {
+	keys, info, tx := newTestMultisig(t, 1, 2)
+
+	outsider, _ := utils.GenerateKeys()
+	if err := tx.SignMultisig(info, outsider); err == nil {
+		t.Error("a key outside the multisig signed")
+	}
+
+	// A 1-of-2 key set can't spend from the 2-of-2 address of the same keys
+	strict := &MultisigInfo{Threshold: 2, PublicKeys: info.PublicKeys}
+	tx.From, _ = strict.Address()
+	if err := tx.SignMultisig(info, keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	if tx.Verify() {
+		t.Error("key set verified for another threshold's address")
+	}
+}
+
+func TestMultisigAddressIgnoresKeyOrder(t *testing.T) {
+	_, info, _ := newTestMultisig(t, 2, 3)
+	reordered := &MultisigInfo{Threshold: 2, PublicKeys: [][]byte{info.PublicKeys[2], info.PublicKeys[0], info.PublicKeys[1]}}
+
+	a, err := info.Address()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := reordered.Address()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Errorf("addresses %s and %s differ for the same key set", a, b)
+	}
+	if _, err := (&MultisigInfo{Threshold: 4, PublicKeys: info.PublicKeys}).Address(); err == nil {
+		t.Error("threshold above the number of keys accepted")
+	}
+}
+
+func TestMultisigValidateBasicLimits(t *testing.T) {
+	keys, info, tx := newTestMultisig(t, 2, 3)
+	for _, key := range keys {
+		if err := tx.SignMultisig(info, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.ValidateBasic(); err != nil {
+		t.Fatalf("3 signatures of a 2-of-3 multisig rejected: %v", err)
+	}
+	if !tx.Verify() {
+		t.Error("signatures beyond the threshold failed verification")
+	}
+
+	tx.Signatures = append(tx.Signatures, tx.Signatures[0])
+	if err := tx.ValidateBasic(); err == nil {
+		t.Error("accepted more signatures than multisig keys")
+	}
+
+	_, largeInfo, large := newTestMultisig(t, 1, MaxMultisigKeys+1)
+	large.Multisig = largeInfo
+	if err := large.ValidateBasic(); err == nil {
+		t.Errorf("accepted a multisig of %d keys", len(largeInfo.PublicKeys))
+	}
+}
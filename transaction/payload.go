@@ -0,0 +1,311 @@
+package transaction
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"matrix-blockchain/utils"
+)
+
+// Payload is the type-specific body of a non-transfer transaction.
+type Payload interface {
+	Validate() error
+}
+
+// StakePayload delegates tokens from the sender to a validator.
+type StakePayload struct {
+	ValidatorID string `json:"validator_id"`
+	Amount      int64  `json:"amount"`
+}
+
+// UnstakePayload withdraws the sender's stake from a validator.
+type UnstakePayload struct {
+	ValidatorID string `json:"validator_id"`
+	Amount      int64  `json:"amount"`
+}
+
+// RedelegatePayload moves the sender's stake between two validators.
+type RedelegatePayload struct {
+	SrcValidatorID string `json:"src_validator_id"`
+	DstValidatorID string `json:"dst_validator_id"`
+	Amount         int64  `json:"amount"`
+}
+
+// RegisterValidatorPayload registers the sender as a validator with a self-bond,
+// the key that will sign its consensus votes, its commission and its public
+// description.
+type RegisterValidatorPayload struct {
+	SelfBond                int64  `json:"self_bond"`
+	ConsensusPubKey         []byte `json:"consensus_pub_key"`
+	CommissionRate          int64  `json:"commission_rate"`            // In basis points
+	MaxCommissionChangeRate int64  `json:"max_commission_change_rate"` // In basis points
+	Moniker                 string `json:"moniker"`
+	Website                 string `json:"website"`
+	Contact                 string `json:"contact"`
+}
+
+// EditValidatorPayload replaces the sender's validator description and
+// optionally changes its commission rate.
+type EditValidatorPayload struct {
+	Moniker        string `json:"moniker"`
+	Website        string `json:"website"`
+	Contact        string `json:"contact"`
+	CommissionRate *int64 `json:"commission_rate,omitempty"` // In basis points, unchanged when omitted
+}
+
+// GovernanceVotePayload casts the sender's vote on a governance proposal.
+type GovernanceVotePayload struct {
+	ProposalID uint64 `json:"proposal_id"`
+	Option     string `json:"option"`
+}
+
+// SubmitProposalPayload opens a governance proposal for voting.
+type SubmitProposalPayload struct {
+	Type        string         `json:"type"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Grant       *TreasuryGrant `json:"grant,omitempty"` // Set for treasury grant proposals
+
+	// ParamChanges maps chain parameters, by their JSON names, to new values.
+	// Set for parameter change proposals.
+	ParamChanges map[string]json.RawMessage `json:"param_changes,omitempty"`
+}
+
+// TreasuryGrant asks for research treasury funds paid to a recipient in
+// milestones.
+type TreasuryGrant struct {
+	Recipient  string      `json:"recipient"`
+	Milestones []Milestone `json:"milestones"`
+}
+
+// Milestone is a part of a grant its recipient can claim from UnlockHeight.
+type Milestone struct {
+	Description  string `json:"description"`
+	Amount       int64  `json:"amount"`
+	UnlockHeight int64  `json:"unlock_height"`
+}
+
+// ResearchGrantClaimPayload claims the unlocked milestones of a research grant
+// awarded to the sender.
+type ResearchGrantClaimPayload struct {
+	GrantID string `json:"grant_id"`
+}
+
+// UnjailPayload returns the sender's jailed validator to the validator set.
+type UnjailPayload struct{}
+
+// WithdrawRewardsPayload pays out the sender's staking rewards and commission.
+type WithdrawRewardsPayload struct{}
+
+// Governance vote options.
+const (
+	VoteYes     = "yes"
+	VoteNo      = "no"
+	VoteAbstain = "abstain"
+)
+
+// Governance proposal types.
+const (
+	ProposalTreasuryGrant = "treasury-grant"
+	ProposalParamChange   = "param-change"
+)
+
+// Limits on governance proposals.
+const (
+	MaxProposalTitleLength       = 140
+	MaxProposalDescriptionLength = 10000
+	MaxGrantMilestones           = 20
+)
+
+func (p *StakePayload) Validate() error {
+	if p.ValidatorID == "" {
+		return errors.New("stake requires a validator ID")
+	}
+	if p.Amount <= 0 {
+		return errors.New("stake amount must be positive")
+	}
+	return nil
+}
+
+func (p *UnstakePayload) Validate() error {
+	if p.ValidatorID == "" {
+		return errors.New("unstake requires a validator ID")
+	}
+	if p.Amount <= 0 {
+		return errors.New("unstake amount must be positive")
+	}
+	return nil
+}
+
+func (p *RedelegatePayload) Validate() error {
+	if p.SrcValidatorID == "" || p.DstValidatorID == "" {
+		return errors.New("redelegate requires source and destination validators")
+	}
+	if p.SrcValidatorID == p.DstValidatorID {
+		return errors.New("cannot redelegate to the same validator")
+	}
+	if p.Amount <= 0 {
+		return errors.New("redelegate amount must be positive")
+	}
+	return nil
+}
+
+func (p *RegisterValidatorPayload) Validate() error {
+	if p.SelfBond <= 0 {
+		return errors.New("validator self-bond must be positive")
+	}
+	if _, err := utils.DecodePublicKey(p.ConsensusPubKey); err != nil {
+		return fmt.Errorf("invalid consensus key: %v", err)
+	}
+	if p.Moniker == "" {
+		return errors.New("validator moniker is required")
+	}
+	return nil
+}
+
+func (p *EditValidatorPayload) Validate() error {
+	if p.Moniker == "" {
+		return errors.New("validator moniker is required")
+	}
+	return nil
+}
+
+func (p *GovernanceVotePayload) Validate() error {
+	switch p.Option {
+	case VoteYes, VoteNo, VoteAbstain:
+		return nil
+	default:
+		return fmt.Errorf("invalid vote option: %s", p.Option)
+	}
+}
+
+func (p *SubmitProposalPayload) Validate() error {
+	if p.Title == "" || len(p.Title) > MaxProposalTitleLength {
+		return fmt.Errorf("proposal title must be 1 to %d bytes", MaxProposalTitleLength)
+	}
+	if len(p.Description) > MaxProposalDescriptionLength {
+		return fmt.Errorf("proposal description longer than %d bytes", MaxProposalDescriptionLength)
+	}
+
+	switch p.Type {
+	case ProposalTreasuryGrant:
+		if p.Grant == nil {
+			return errors.New("treasury grant proposal requires a grant")
+		}
+		return p.Grant.Validate()
+	case ProposalParamChange:
+		if len(p.ParamChanges) == 0 {
+			return errors.New("parameter change proposal requires parameter changes")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown proposal type: %s", p.Type)
+	}
+}
+
+// Validate checks the recipient and the milestone schedule, which must be
+// ordered by unlock height.
+func (g *TreasuryGrant) Validate() error {
+	if !utils.ValidateAddress(g.Recipient) {
+		return fmt.Errorf("invalid grant recipient: %s", g.Recipient)
+	}
+	if len(g.Milestones) == 0 || len(g.Milestones) > MaxGrantMilestones {
+		return fmt.Errorf("grant must have 1 to %d milestones", MaxGrantMilestones)
+	}
+
+	var total, lastUnlock int64
+	for i, milestone := range g.Milestones {
+		if milestone.Amount <= 0 {
+			return fmt.Errorf("milestone %d amount must be positive", i)
+		}
+		if milestone.UnlockHeight < lastUnlock {
+			return errors.New("milestones must be ordered by unlock height")
+		}
+		if total+milestone.Amount < total {
+			return errors.New("grant amount overflows")
+		}
+		total += milestone.Amount
+		lastUnlock = milestone.UnlockHeight
+	}
+	return nil
+}
+
+// Total returns the sum of the grant's milestones.
+func (g *TreasuryGrant) Total() int64 {
+	var total int64
+	for _, milestone := range g.Milestones {
+		total += milestone.Amount
+	}
+	return total
+}
+
+func (p *ResearchGrantClaimPayload) Validate() error {
+	if p.GrantID == "" {
+		return errors.New("grant claim requires a grant ID")
+	}
+	return nil
+}
+
+func (p *UnjailPayload) Validate() error {
+	return nil
+}
+
+func (p *WithdrawRewardsPayload) Validate() error {
+	return nil
+}
+
+// DecodePayload unmarshals the transaction payload into its typed form.
+func (t *Transaction) DecodePayload() (Payload, error) {
+	var payload Payload
+	switch t.Type {
+	case TxStake:
+		payload = &StakePayload{}
+	case TxUnstake:
+		payload = &UnstakePayload{}
+	case TxRedelegate:
+		payload = &RedelegatePayload{}
+	case TxRegisterValidator:
+		payload = &RegisterValidatorPayload{}
+	case TxEditValidator:
+		payload = &EditValidatorPayload{}
+	case TxGovernanceVote:
+		payload = &GovernanceVotePayload{}
+	case TxSubmitProposal:
+		payload = &SubmitProposalPayload{}
+	case TxResearchGrantClaim:
+		payload = &ResearchGrantClaimPayload{}
+	case TxUnjail:
+		payload = &UnjailPayload{}
+	case TxWithdrawRewards:
+		payload = &WithdrawRewardsPayload{}
+	default:
+		return nil, fmt.Errorf("unknown transaction type: %s", t.Type)
+	}
+
+	if err := json.Unmarshal(t.Payload, payload); err != nil {
+		return nil, fmt.Errorf("invalid %s payload: %v", t.Type, err)
+	}
+	return payload, nil
+}
+
+// Debit returns what a transaction takes from its sender's spendable balance
+// besides its fee: the amount of a transfer or a stake, or a new validator's
+// self-bond. Payloads that fail to decode debit nothing, ValidateBasic
+// rejects them.
+func (t *Transaction) Debit() int64 {
+	if t.Type == TxTransfer {
+		return t.Amount
+	}
+	payload, err := t.DecodePayload()
+	if err != nil {
+		return 0
+	}
+	switch p := payload.(type) {
+	case *StakePayload:
+		return p.Amount
+	case *RegisterValidatorPayload:
+		return p.SelfBond
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,38 @@
+package transaction
+
+import "strings"
+
+// Receipt records the outcome of a transaction included in a block.
+type Receipt struct {
+	TxHash     string `json:"tx_hash"`
+	BlockIndex int    `json:"block_index"`
+	TxIndex    int    `json:"tx_index"` // Position of the transaction in the block
+	Type       TxType `json:"type"`
+	From       string `json:"from"`
+	To         string `json:"to,omitempty"`
+	Amount     int64  `json:"amount,omitempty"`
+	Fee        int64  `json:"fee"`
+	Memo       string `json:"memo,omitempty"`
+}
+
+// MemoTag returns the tag a memo is indexed under: the text before the first
+// ':' (e.g. "grant" for "grant:42"), or the whole memo if it has none.
+func MemoTag(memo string) string {
+	tag, _, _ := strings.Cut(memo, ":")
+	return strings.TrimSpace(tag)
+}
+
+// NewReceipt builds the receipt for a transaction included at the given position.
+func NewReceipt(tx *Transaction, blockIndex, txIndex int) Receipt {
+	return Receipt{
+		TxHash:     tx.Hash(),
+		BlockIndex: blockIndex,
+		TxIndex:    txIndex,
+		Type:       tx.Type,
+		From:       tx.From,
+		To:         tx.To,
+		Amount:     tx.Amount,
+		Fee:        tx.Fee,
+		Memo:       tx.Memo,
+	}
+}
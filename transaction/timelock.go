@@ -0,0 +1,57 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+)
+
+// LockTimeThreshold separates the two meanings of ValidAfter and ValidUntil:
+// values below it are block heights, values at or above it are Unix timestamps.
+const LockTimeThreshold = 500000000
+
+// isTimestamp reports whether a lock value is a Unix timestamp rather than a height.
+func isTimestamp(lock int64) bool {
+	return lock >= LockTimeThreshold
+}
+
+// validateTimeLock performs the stateless checks on the validity window.
+func (t *Transaction) validateTimeLock() error {
+	if t.ValidAfter < 0 || t.ValidUntil < 0 {
+		return errors.New("time lock values must not be negative")
+	}
+	if t.ValidAfter != 0 && t.ValidUntil != 0 &&
+		isTimestamp(t.ValidAfter) == isTimestamp(t.ValidUntil) && t.ValidUntil < t.ValidAfter {
+		return fmt.Errorf("transaction expires at %d before it becomes valid at %d", t.ValidUntil, t.ValidAfter)
+	}
+	return nil
+}
+
+// CheckTimeLock reports whether the transaction may be included in a block
+// at the given height and block time.
+func (t *Transaction) CheckTimeLock(height int64, blockTime int64) error {
+	if t.ValidAfter != 0 {
+		current := height
+		if isTimestamp(t.ValidAfter) {
+			current = blockTime
+		}
+		if current < t.ValidAfter {
+			return fmt.Errorf("transaction %s is not valid until %d", t.Hash(), t.ValidAfter)
+		}
+	}
+	if t.IsExpired(height, blockTime) {
+		return fmt.Errorf("transaction %s expired at %d", t.Hash(), t.ValidUntil)
+	}
+	return nil
+}
+
+// IsExpired reports whether the transaction's ValidUntil has passed.
+func (t *Transaction) IsExpired(height int64, blockTime int64) bool {
+	if t.ValidUntil == 0 {
+		return false
+	}
+	current := height
+	if isTimestamp(t.ValidUntil) {
+		current = blockTime
+	}
+	return current > t.ValidUntil
+}
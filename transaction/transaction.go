@@ -2,46 +2,203 @@ package transaction
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
 	"matrix-blockchain/utils"
 	"time"
 )
 
+// TxType identifies the kind of state change a transaction performs.
+type TxType string
+
+const (
+	TxTransfer           TxType = "transfer"
+	TxStake              TxType = "stake"
+	TxUnstake            TxType = "unstake"
+	TxRedelegate         TxType = "redelegate"
+	TxRegisterValidator  TxType = "register-validator"
+	TxEditValidator      TxType = "edit-validator"
+	TxGovernanceVote     TxType = "governance-vote"
+	TxSubmitProposal     TxType = "submit-proposal"
+	TxResearchGrantClaim TxType = "research-grant-claim"
+	TxUnjail             TxType = "unjail"
+	TxWithdrawRewards    TxType = "withdraw-rewards"
+)
+
+// Signature is an ECDSA signature over the transaction's signing bytes.
+type Signature struct {
+	R *big.Int
+	S *big.Int
+}
+
 type Transaction struct {
+	Type      TxType
 	From      string
 	To        string
 	Amount    int64
+	Nonce     uint64 // Sender's sequence number, prevents replays
 	Timestamp int64
-	Signature *ecdsa.Signature
+	Payload   json.RawMessage // Type-specific payload, empty for transfers
+	Fee       int64           // Fee paid by the sender, at least RequiredFee
+	Memo      string          // Free-form reference, at most MaxMemoSize bytes
+	PublicKey []byte          // Sender's encoded public key
+	Signature *Signature
+
+	ValidAfter int64 // Earliest height or time the transaction may be included, 0 for none
+	ValidUntil int64 // Last height or time the transaction may be included, 0 for no expiry
+
+	Multisig   *MultisigInfo       // Key set of a multisig sender, nil for single-key senders
+	Signatures []MultisigSignature // Signatures of the multisig signers
+}
+
+// signDoc is the canonical form of a transaction covered by its signature.
+type signDoc struct {
+	Type      TxType          `json:"type"`
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Amount    int64           `json:"amount"`
+	Nonce     uint64          `json:"nonce"`
+	Timestamp int64           `json:"timestamp"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Fee       int64           `json:"fee"`
+	Memo      string          `json:"memo,omitempty"`
+
+	ValidAfter int64 `json:"valid_after,omitempty"`
+	ValidUntil int64 `json:"valid_until,omitempty"`
 }
 
-// NewTransaction creates a new transaction, signs it with the sender's private key
-func NewTransaction(from, to string, amount int64, privateKey *ecdsa.PrivateKey) (*Transaction, error) {
+// NewTransaction creates a new transfer transaction, signs it with the sender's private key
+func NewTransaction(from, to string, amount int64, nonce uint64, privateKey *ecdsa.PrivateKey) (*Transaction, error) {
 	// Create a new transaction with necessary details
 	transaction := &Transaction{
+		Type:      TxTransfer,
 		From:      from,
 		To:        to,
 		Amount:    amount,
+		Nonce:     nonce,
 		Timestamp: time.Now().Unix(),
+		Fee:       BaseFee,
 	}
 
-	// Signing the transaction by hashing the details and using the private key
-	hash := fmt.Sprintf("%s:%d:%d", transaction.From, transaction.Amount, transaction.Timestamp)
-	r, s, err := utils.SignTransaction(privateKey, []byte(hash))
+	if err := transaction.Sign(privateKey); err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// NewTypedTransaction creates and signs a transaction carrying a type-specific payload.
+func NewTypedTransaction(txType TxType, from string, payload Payload, nonce uint64, privateKey *ecdsa.PrivateKey) (*Transaction, error) {
+	data, err := json.Marshal(payload)
 	if err != nil {
-		return nil, fmt.Errorf("failed to sign transaction: %v", err)
+		return nil, fmt.Errorf("failed to encode payload: %v", err)
+	}
+
+	transaction := &Transaction{
+		Type:      txType,
+		From:      from,
+		Nonce:     nonce,
+		Timestamp: time.Now().Unix(),
+		Payload:   data,
+		Fee:       BaseFee,
 	}
-	transaction.Signature = &ecdsa.Signature{R: r, S: s}
 
+	if err := transaction.ValidateBasic(); err != nil {
+		return nil, err
+	}
+	if err := transaction.Sign(privateKey); err != nil {
+		return nil, err
+	}
 	return transaction, nil
 }
 
-// Verify checks the transaction's signature to ensure its authenticity
+// SigningBytes returns the canonical bytes covered by the transaction signature.
+func (t *Transaction) SigningBytes() []byte {
+	data, _ := json.Marshal(signDoc{
+		Type:      t.Type,
+		From:      t.From,
+		To:        t.To,
+		Amount:    t.Amount,
+		Nonce:     t.Nonce,
+		Timestamp: t.Timestamp,
+		Payload:   t.Payload,
+		Fee:       t.Fee,
+		Memo:      t.Memo,
+
+		ValidAfter: t.ValidAfter,
+		ValidUntil: t.ValidUntil,
+	})
+	return data
+}
+
+// Hash returns the transaction identifier.
+func (t *Transaction) Hash() string {
+	return utils.Hash(t.SigningBytes())
+}
+
+// Sign signs the transaction and attaches the signer's public key.
+func (t *Transaction) Sign(privateKey *ecdsa.PrivateKey) error {
+	// Signing the transaction by hashing the details and using the private key
+	r, s, err := utils.SignTransaction(privateKey, t.SigningBytes())
+	if err != nil {
+		return fmt.Errorf("failed to sign transaction: %v", err)
+	}
+	t.PublicKey = utils.EncodePublicKey(&privateKey.PublicKey)
+	t.Signature = &Signature{R: r, S: s}
+	return nil
+}
+
+// ValidateBasic performs stateless checks on the transaction and its payload.
+func (t *Transaction) ValidateBasic() error {
+	if !utils.ValidateAddress(t.From) {
+		return fmt.Errorf("invalid sender address: %s", t.From)
+	}
+	if err := t.validateTimeLock(); err != nil {
+		return err
+	}
+	if err := t.validateFee(); err != nil {
+		return err
+	}
+	if err := t.validateMultisig(); err != nil {
+		return err
+	}
+
+	if t.Type == TxTransfer {
+		if !utils.ValidateAddress(t.To) {
+			return fmt.Errorf("invalid recipient address: %s", t.To)
+		}
+		if t.Amount <= 0 {
+			return errors.New("transfer amount must be positive")
+		}
+		return nil
+	}
+
+	if t.To != "" || t.Amount != 0 {
+		return fmt.Errorf("%s transaction must carry its values in the payload", t.Type)
+	}
+	payload, err := t.DecodePayload()
+	if err != nil {
+		return err
+	}
+	return payload.Validate()
+}
+
+// Verify checks the transaction's signature to ensure its authenticity.
+// Multisig transactions must carry at least the threshold of valid signatures.
 func (t *Transaction) Verify() bool {
-	// Hash the transaction details (from, to, amount, timestamp)
-	hash := fmt.Sprintf("%s:%d:%d", t.From, t.Amount, t.Timestamp)
+	if t.Multisig != nil {
+		return t.verifyMultisig()
+	}
 
-	// Retrieve the sender's public key from their address and verify the signature
-	publicKey := utils.GetPublicKeyFromAddress(t.From) // This should map an address to its public key
-	return utils.VerifySignature(publicKey, []byte(hash), t.Signature.R, t.Signature.S)
+	if t.Signature == nil || t.Signature.R == nil || t.Signature.S == nil {
+		return false
+	}
+
+	// The embedded public key must belong to the sender's address
+	publicKey, err := utils.DecodePublicKey(t.PublicKey)
+	if err != nil || utils.PublicKeyToAddress(publicKey) != t.From {
+		return false
+	}
+	return utils.VerifySignature(publicKey, t.SigningBytes(), t.Signature.R, t.Signature.S)
 }
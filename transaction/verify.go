@@ -0,0 +1,166 @@
+package transaction
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"matrix-blockchain/utils"
+	"runtime"
+	"sync"
+)
+
+const defaultSignatureCacheSize = 100000
+
+// SignatureCache remembers transactions whose signatures have already been
+// verified so the mempool and block import don't check them twice.
+// A nil cache is valid and caches nothing.
+type SignatureCache struct {
+	mutex    sync.Mutex
+	entries  map[string]struct{}
+	order    []string // Insertion order, oldest entries are evicted first
+	capacity int
+}
+
+// NewSignatureCache creates a cache holding up to capacity verified signatures.
+func NewSignatureCache(capacity int) *SignatureCache {
+	if capacity <= 0 {
+		capacity = defaultSignatureCacheSize
+	}
+	return &SignatureCache{
+		entries:  make(map[string]struct{}),
+		capacity: capacity,
+	}
+}
+
+// Contains reports whether the transaction's signature is known to be valid.
+func (c *SignatureCache) Contains(tx *Transaction) bool {
+	if c == nil {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_, exists := c.entries[signatureKey(tx)]
+	return exists
+}
+
+// Add records the transaction's signature as valid.
+func (c *SignatureCache) Add(tx *Transaction) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := signatureKey(tx)
+	if _, exists := c.entries[key]; exists {
+		return
+	}
+	if len(c.order) >= c.capacity {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.entries[key] = struct{}{}
+	c.order = append(c.order, key)
+}
+
+// signatureKey identifies a transaction together with the key and signature
+// that were checked, so a tampered signature never hits the cache. Every field
+// is length-prefixed so bytes can't be moved from one field to the next.
+func signatureKey(tx *Transaction) string {
+	data := appendField(nil, tx.SigningBytes())
+	data = appendField(data, tx.PublicKey)
+	data = appendSignature(data, tx.Signature)
+	if tx.Multisig != nil {
+		data = binary.BigEndian.AppendUint64(data, uint64(tx.Multisig.Threshold))
+		data = binary.BigEndian.AppendUint64(data, uint64(len(tx.Multisig.PublicKeys)))
+		for _, key := range tx.Multisig.PublicKeys {
+			data = appendField(data, key)
+		}
+		data = binary.BigEndian.AppendUint64(data, uint64(len(tx.Signatures)))
+		for _, sig := range tx.Signatures {
+			data = binary.BigEndian.AppendUint64(data, uint64(sig.Index))
+			data = appendSignature(data, sig.Signature)
+		}
+	}
+	return utils.Hash(data)
+}
+
+// appendField appends a field to a cache key, prefixed with its length.
+func appendField(data []byte, field []byte) []byte {
+	data = binary.BigEndian.AppendUint64(data, uint64(len(field)))
+	return append(data, field...)
+}
+
+// appendSignature appends a signature to a cache key, keeping the sign of R
+// and S, which Bytes drops.
+func appendSignature(data []byte, sig *Signature) []byte {
+	if sig == nil || sig.R == nil || sig.S == nil {
+		return append(data, 0)
+	}
+	data = append(data, 1, byte(sig.R.Sign()+1))
+	data = appendField(data, sig.R.Bytes())
+	data = append(data, byte(sig.S.Sign()+1))
+	return appendField(data, sig.S.Bytes())
+}
+
+// VerifyWithCache verifies the transaction's signature, skipping the check if
+// the cache already holds it and caching the result on success.
+func VerifyWithCache(tx *Transaction, cache *SignatureCache) bool {
+	if cache.Contains(tx) {
+		return true
+	}
+	if !tx.Verify() {
+		return false
+	}
+	cache.Add(tx)
+	return true
+}
+
+// VerifyTransactions checks all transaction signatures on a pool of workers.
+// Verification stops at the first invalid signature.
+func VerifyTransactions(txs []Transaction, cache *SignatureCache) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	workers := runtime.NumCPU()
+	if workers > len(txs) {
+		workers = len(txs)
+	}
+
+	jobs := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				if !VerifyWithCache(&txs[i], cache) {
+					once.Do(func() {
+						firstErr = fmt.Errorf("invalid signature on transaction %s", txs[i].Hash())
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+	for i := range txs {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return firstErr
+}
@@ -0,0 +1,39 @@
+package transaction
+
+import (
+	"math/big"
+	"matrix-blockchain/utils"
+	"testing"
+)
+
+func TestSignatureCacheRejectsResplitSignature(t *testing.T) {
+	key, pub := utils.GenerateKeys()
+	tx, err := NewTransaction(utils.PublicKeyToAddress(pub), "MRX-Receiver", 10, 0, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := NewSignatureCache(0)
+	if !VerifyWithCache(tx, cache) {
+		t.Fatal("valid signature rejected")
+	}
+
+	// Move the first byte of S onto the end of R
+	r, s := tx.Signature.R.Bytes(), tx.Signature.S.Bytes()
+	resplit := *tx
+	resplit.Signature = &Signature{
+		R: new(big.Int).SetBytes(append(append([]byte{}, r...), s[0])),
+		S: new(big.Int).SetBytes(s[1:]),
+	}
+	if resplit.Verify() {
+		t.Fatal("re-split signature verified")
+	}
+	if VerifyWithCache(&resplit, cache) {
+		t.Error("re-split signature accepted from the cache")
+	}
+
+	negated := *tx
+	negated.Signature = &Signature{R: new(big.Int).Neg(tx.Signature.R), S: tx.Signature.S}
+	if VerifyWithCache(&negated, cache) {
+		t.Error("negated signature accepted from the cache")
+	}
+}
@@ -5,9 +5,15 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/x509"
 	"encoding/hex"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"math/big"
+	"os"
+	"sort"
+	"strings"
 )
 
 func GenerateKeys() (*ecdsa.PrivateKey, *ecdsa.PublicKey) {
@@ -30,8 +36,81 @@ func VerifySignature(publicKey *ecdsa.PublicKey, data []byte, r, s *big.Int) boo
 	return ecdsa.Verify(publicKey, hash[:], r, s)
 }
 
+// EncodePublicKey serializes a public key into its uncompressed point form.
+func EncodePublicKey(pubKey *ecdsa.PublicKey) []byte {
+	return elliptic.Marshal(elliptic.P256(), pubKey.X, pubKey.Y)
+}
+
+// DecodePublicKey parses a public key produced by EncodePublicKey.
+func DecodePublicKey(data []byte) (*ecdsa.PublicKey, error) {
+	x, y := elliptic.Unmarshal(elliptic.P256(), data)
+	if x == nil {
+		return nil, errors.New("invalid public key encoding")
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+}
+
 func PublicKeyToAddress(pubKey *ecdsa.PublicKey) string {
-	hash := sha256.Sum256([]byte(fmt.Sprintf("%x", pubKey)))
+	// Hash the encoded point rather than the struct so every node derives the same address
+	hash := sha256.Sum256(EncodePublicKey(pubKey))
 	address := fmt.Sprintf("MRX-%s", hex.EncodeToString(hash[:]))[:34] // Address starts with MRX
 	return address
 }
+
+// MultisigAddress derives the address controlled by threshold-of-N of the
+// given encoded public keys. Key order does not affect the address.
+func MultisigAddress(threshold int, pubKeys [][]byte) (string, error) {
+	if len(pubKeys) == 0 {
+		return "", errors.New("multisig requires at least one public key")
+	}
+	if threshold <= 0 || threshold > len(pubKeys) {
+		return "", fmt.Errorf("invalid multisig threshold %d of %d", threshold, len(pubKeys))
+	}
+
+	sorted := make([]string, len(pubKeys))
+	for i, key := range pubKeys {
+		if _, err := DecodePublicKey(key); err != nil {
+			return "", err
+		}
+		sorted[i] = hex.EncodeToString(key)
+	}
+	sort.Strings(sorted)
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i] == sorted[i-1] {
+			return "", errors.New("duplicate public key in multisig")
+		}
+	}
+
+	hash := sha256.Sum256([]byte(fmt.Sprintf("multisig:%d:%s", threshold, strings.Join(sorted, ","))))
+	address := fmt.Sprintf("MRX-%s", hex.EncodeToString(hash[:]))[:34] // Same scheme as single-key addresses
+	return address, nil
+}
+
+// LoadOrGenerateKey reads a PEM-encoded private key from path, generating
+// and saving a new key if the file does not exist.
+func LoadOrGenerateKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate key: %v", err)
+		}
+		der, err := x509.MarshalECPrivateKey(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode key: %v", err)
+		}
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+			return nil, fmt.Errorf("failed to save key: %v", err)
+		}
+		return privateKey, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %v", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM key found in %s", path)
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}